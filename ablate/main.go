@@ -0,0 +1,130 @@
+// Command ablate measures how much a model relies on
+// style versus content.
+//
+// Every validation batch is evaluated several times, each
+// time with a different transformation applied to the
+// tweets (e.g. lowercasing everything or replacing the
+// content words).
+// A large accuracy drop under a transformation means the
+// model depends on whatever that transformation removes.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"math/rand"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/unixpickle/essentials"
+	"github.com/unixpickle/serializer"
+	"github.com/unixpickle/tweeters"
+)
+
+func main() {
+	rand.Seed(time.Now().UnixNano())
+
+	var modelPath string
+	var dbPath string
+	var validation float64
+	var prob float64
+	var batchSize int
+	var numBatches int
+	var minTweets, maxTweets int
+	var transformList string
+	flag.StringVar(&modelPath, "model", "../train/model_out", "path to trained model")
+	flag.StringVar(&dbPath, "data", "", "path to tweet DB")
+	flag.Float64Var(&validation, "validation", 0.1, "validation fraction used to train")
+	flag.Float64Var(&prob, "prob", 0.5, "probability of same user")
+	flag.IntVar(&batchSize, "batch", 64, "batch size")
+	flag.IntVar(&numBatches, "batches", 50, "number of batches to evaluate")
+	flag.IntVar(&minTweets, "min", 3, "minimum tweets per user")
+	flag.IntVar(&maxTweets, "max", 16, "maximum tweets per user")
+	flag.StringVar(&transformList, "transforms", strings.Join(TransformNames, ","),
+		"comma-separated transforms to evaluate")
+	flag.Parse()
+
+	if dbPath == "" {
+		essentials.Die("Required flag: -data. See -help.")
+	}
+	// The untransformed accuracy is always measured first,
+	// since every other result is relative to it.
+	names := []string{"identity"}
+	for _, name := range strings.Split(transformList, ",") {
+		if _, ok := Transforms[name]; !ok {
+			essentials.Die("unknown transform:", name)
+		}
+		if name != "identity" {
+			names = append(names, name)
+		}
+	}
+
+	log.Println("Loading model...")
+	var model *tweeters.Model
+	if err := serializer.LoadAny(modelPath, &model); err != nil {
+		essentials.Die(err)
+	}
+	model.SetDropout(false)
+
+	log.Println("Loading DB...")
+	db, err := tweeters.OpenDB(dbPath)
+	if err != nil {
+		essentials.Die(err)
+	}
+	samples := tweeters.NewSamples(db)
+	samples.Truncation = model.Truncation
+	_, testing := samples.Partition(validation)
+	log.Printf("%d testing users", len(testing.UserIndices))
+
+	numCorrect := make([]float64, len(names))
+	var numTotal float64
+	for i := 0; i < numBatches; i++ {
+		tweets, avg, labels, err := testing.Batch(prob, batchSize, minTweets, maxTweets)
+		if err != nil {
+			essentials.Die(err)
+		}
+		for j, name := range names {
+			transformed := Transforms[name](tweets)
+			for k, t := range transformed {
+				if len(t) == 0 {
+					transformed[k] = []byte(" ")
+				}
+			}
+			numCorrect[j] += countCorrect(model, transformed, avg, labels)
+		}
+		numTotal += float64(len(labels))
+		log.Printf("batch %d/%d: %s", i+1, numBatches,
+			summary(names, numCorrect, numTotal))
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "transform\taccuracy\tchange")
+	for j, name := range names {
+		acc := numCorrect[j] / numTotal
+		fmt.Fprintf(w, "%s\t%.2f%%\t%+.2f%%\n", name, 100*acc,
+			100*(acc-numCorrect[0]/numTotal))
+	}
+	w.Flush()
+}
+
+func countCorrect(model *tweeters.Model, tweets [][]byte, avg []int,
+	labels []float64) float64 {
+	var correct float64
+	for i, logit := range tweeters.VectorFloats(model.Classify(tweets, avg).Output()) {
+		if (logit > 0) == (labels[i] > 0.5) {
+			correct++
+		}
+	}
+	return correct
+}
+
+func summary(names []string, numCorrect []float64, numTotal float64) string {
+	var parts []string
+	for j, name := range names {
+		parts = append(parts, fmt.Sprintf("%s=%.2f%%", name, 100*numCorrect[j]/numTotal))
+	}
+	return strings.Join(parts, " ")
+}
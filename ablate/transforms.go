@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bytes"
+	"math/rand"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/unixpickle/tweeters"
+)
+
+// A Transform modifies every tweet in a batch.
+//
+// Transforms receive the whole batch so that they can
+// draw replacement text from other tweets.
+type Transform func(tweets [][]byte) [][]byte
+
+// Transforms maps names to the available ablations.
+var Transforms = map[string]Transform{
+	"identity":   mapTweets(func(t []byte) []byte { return t }),
+	"lowercase":  mapTweets(bytes.ToLower),
+	"noemoji":    mapTweets(removeRunes(tweeters.IsEmoji)),
+	"nopunct":    mapTweets(removeRunes(unicode.IsPunct)),
+	"whitespace": mapTweets(normalizeWhitespace),
+	"shuffle":    mapTweets(shuffleWords),
+	"content":    replaceContentWords,
+}
+
+// TransformNames lists the transforms in the order they
+// are reported.
+var TransformNames = []string{"identity", "lowercase", "noemoji", "nopunct",
+	"whitespace", "shuffle", "content"}
+
+var stopwords = map[string]bool{}
+
+func init() {
+	for _, w := range strings.Fields("a an the and or but if of to in on at by for with " +
+		"from as is am are was were be been being i me my you your he him his she her " +
+		"it its we us our they them their this that these those not no so do does did " +
+		"have has had will would can could just") {
+		stopwords[w] = true
+	}
+}
+
+func mapTweets(f func(t []byte) []byte) Transform {
+	return func(tweets [][]byte) [][]byte {
+		res := make([][]byte, len(tweets))
+		for i, t := range tweets {
+			res[i] = f(t)
+		}
+		return res
+	}
+}
+
+func removeRunes(f func(r rune) bool) func(t []byte) []byte {
+	return func(t []byte) []byte {
+		return bytes.Map(func(r rune) rune {
+			if f(r) {
+				return -1
+			}
+			return r
+		}, t)
+	}
+}
+
+func normalizeWhitespace(t []byte) []byte {
+	return []byte(strings.Join(strings.Fields(string(t)), " "))
+}
+
+func shuffleWords(t []byte) []byte {
+	words := strings.Fields(string(t))
+	for i, j := range rand.Perm(len(words)) {
+		words[i], words[j] = words[j], words[i]
+	}
+	return []byte(strings.Join(words, " "))
+}
+
+// replaceContentWords swaps every content word for a
+// random content word from elsewhere in the batch.
+// The capitalization pattern and surrounding punctuation
+// of the original word are kept.
+func replaceContentWords(tweets [][]byte) [][]byte {
+	var pool []string
+	for _, t := range tweets {
+		for _, field := range strings.Fields(string(t)) {
+			if core, _, _ := splitWord(field); isContentWord(core) {
+				pool = append(pool, strings.ToLower(core))
+			}
+		}
+	}
+	res := make([][]byte, len(tweets))
+	for i, t := range tweets {
+		fields := strings.Fields(string(t))
+		for j, field := range fields {
+			core, prefix, suffix := splitWord(field)
+			if isContentWord(core) {
+				fields[j] = prefix + matchCase(pool[rand.Intn(len(pool))], core) + suffix
+			}
+		}
+		res[i] = []byte(strings.Join(fields, " "))
+	}
+	return res
+}
+
+func splitWord(field string) (core, prefix, suffix string) {
+	isLetter := func(r rune) bool {
+		return unicode.IsLetter(r) || r == '\''
+	}
+	start := strings.IndexFunc(field, isLetter)
+	if start < 0 {
+		return "", field, ""
+	}
+	end := strings.LastIndexFunc(field, isLetter)
+	_, size := utf8.DecodeRuneInString(field[end:])
+	return field[start : end+size], field[:start], field[end+size:]
+}
+
+func isContentWord(word string) bool {
+	if word == "" || stopwords[strings.ToLower(word)] {
+		return false
+	}
+	for _, r := range word {
+		if !unicode.IsLetter(r) && r != '\'' {
+			return false
+		}
+	}
+	return true
+}
+
+func matchCase(word, pattern string) string {
+	if strings.ToUpper(pattern) == pattern {
+		return strings.ToUpper(word)
+	}
+	first, _ := utf8.DecodeRuneInString(pattern)
+	if unicode.IsUpper(first) {
+		r, size := utf8.DecodeRuneInString(word)
+		return string(unicode.ToUpper(r)) + word[size:]
+	}
+	return word
+}
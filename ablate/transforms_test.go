@@ -0,0 +1,59 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTransforms(t *testing.T) {
+	in := [][]byte{[]byte("Hello,   World! 😀 I love the   Lakers")}
+	expected := map[string]string{
+		"identity":   "Hello,   World! 😀 I love the   Lakers",
+		"lowercase":  "hello,   world! 😀 i love the   lakers",
+		"noemoji":    "Hello,   World!  I love the   Lakers",
+		"nopunct":    "Hello   World 😀 I love the   Lakers",
+		"whitespace": "Hello, World! 😀 I love the Lakers",
+	}
+	for name, exp := range expected {
+		if actual := string(Transforms[name](in)[0]); actual != exp {
+			t.Errorf("%s: expected %q but got %q", name, exp, actual)
+		}
+	}
+
+	// Symbols which are not emoji should be kept.
+	symbols := [][]byte{[]byte("x^2 `code` © 5°")}
+	if actual := string(Transforms["noemoji"](symbols)[0]); actual != string(symbols[0]) {
+		t.Errorf("noemoji: expected %q but got %q", symbols[0], actual)
+	}
+
+	shuffled := strings.Fields(string(Transforms["shuffle"](in)[0]))
+	if len(shuffled) != 7 {
+		t.Errorf("shuffle: unexpected words %v", shuffled)
+	}
+}
+
+func TestReplaceContentWords(t *testing.T) {
+	in := [][]byte{[]byte("I love the Lakers!"), []byte("GO dogs, @bob")}
+	out := replaceContentWords(in)
+	pool := map[string]bool{"love": true, "lakers": true, "go": true, "dogs": true,
+		"bob": true}
+
+	fields := strings.Fields(string(out[0]))
+	if len(fields) != 4 || fields[0] != "I" || fields[2] != "the" {
+		t.Fatalf("unexpected output: %q", out[0])
+	}
+	if !pool[fields[1]] {
+		t.Errorf("unexpected replacement: %q", fields[1])
+	}
+	last := strings.TrimSuffix(fields[3], "!")
+	if last == fields[3] || !pool[strings.ToLower(last)] ||
+		last[:1] != strings.ToUpper(last[:1]) {
+		t.Errorf("unexpected replacement: %q", fields[3])
+	}
+
+	fields = strings.Fields(string(out[1]))
+	if fields[0] != strings.ToUpper(fields[0]) || !strings.HasPrefix(fields[2], "@") ||
+		!strings.HasSuffix(fields[1], ",") {
+		t.Errorf("unexpected output: %q", out[1])
+	}
+}
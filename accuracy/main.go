@@ -6,8 +6,6 @@ import (
 	"math/rand"
 	"time"
 
-	"github.com/unixpickle/anyvec"
-	"github.com/unixpickle/anyvec/anyvec32"
 	"github.com/unixpickle/essentials"
 	"github.com/unixpickle/serializer"
 	"github.com/unixpickle/tweeters"
@@ -48,30 +46,25 @@ func main() {
 		essentials.Die(err)
 	}
 	samples := tweeters.NewSamples(db)
+	samples.Truncation = model.Truncation
 	_, testing := samples.Partition(validation)
 	log.Printf("%d testing users", len(testing.UserIndices))
 
-	c := anyvec32.CurrentCreator()
-
 	log.Println("Computing accuracy...")
 	var numCorrect float64
 	var numTotal float64
 	for {
-		tweets, avg, labelFloat, err := testing.Batch(prob, batchSize, minTweets, maxTweets)
+		tweets, avg, labels, err := testing.Batch(prob, batchSize, minTweets, maxTweets)
 		if err != nil {
 			essentials.Die(err)
 		}
-		labels := c.MakeVectorData(c.MakeNumericList(labelFloat))
-		latent := model.Averages(tweets, avg)
-		out := model.Classifier.Apply(latent, len(avg)/2).Output()
-		anyvec.GreaterThan(out, float32(0))
-
-		numCorrect += float64(out.Dot(labels).(float32))
-		anyvec.Complement(out)
-		anyvec.Complement(labels)
-		numCorrect += float64(out.Dot(labels).(float32))
-
-		numTotal += float64(labels.Len())
+		logits := tweeters.VectorFloats(model.Classify(tweets, avg).Output())
+		for i, logit := range logits {
+			if (logit > 0) == (labels[i] > 0.5) {
+				numCorrect++
+			}
+		}
+		numTotal += float64(len(labels))
 		log.Printf("Got %.2f%% (out of %d)", 100*numCorrect/numTotal, int(numTotal))
 	}
 }
@@ -0,0 +1,116 @@
+package tweeters
+
+import (
+	"errors"
+	"math"
+
+	"github.com/unixpickle/anydiff"
+	"github.com/unixpickle/anynet"
+	"github.com/unixpickle/anyvec"
+	"github.com/unixpickle/essentials"
+	"github.com/unixpickle/serializer"
+)
+
+func init() {
+	serializer.RegisterTypedDeserializer((&AttentionAggregator{}).SerializerType(),
+		DeserializeAttentionAggregator)
+}
+
+// An Aggregator combines the latent vectors of a user's
+// context tweets into a single vector, taking into
+// account the candidate tweet it will be compared to.
+type Aggregator interface {
+	serializer.Serializer
+
+	// Aggregate combines n packed context vectors into one
+	// vector for the given candidate vector.
+	Aggregate(context anydiff.Res, n int, candidate anydiff.Res) anydiff.Res
+}
+
+// NewAggregator creates an Aggregator by name.
+//
+// The name may be "mean" or "attention".
+// Plain averaging is represented by a nil Aggregator.
+// An empty name is equivalent to "mean".
+func NewAggregator(c anyvec.Creator, name string, latentSize int) (Aggregator, error) {
+	switch name {
+	case "", "mean":
+		return nil, nil
+	case "attention":
+		return NewAttentionAggregator(c, latentSize, latentSize/4), nil
+	default:
+		return nil, errors.New("unknown aggregator: " + name)
+	}
+}
+
+// AttentionAggregator computes a weighted average of the
+// context vectors, where the weights are a softmax over
+// the dot products between projected context vectors
+// (keys) and the projected candidate vector (query).
+//
+// This lets the model ignore context tweets which are
+// irrelevant to the candidate.
+type AttentionAggregator struct {
+	Keys  *anynet.FC
+	Query *anynet.FC
+}
+
+// NewAttentionAggregator creates an AttentionAggregator
+// with keys of size keySize.
+//
+// The query projection is initialized to zero, so the
+// aggregator initially computes a plain average.
+func NewAttentionAggregator(c anyvec.Creator, latentSize,
+	keySize int) *AttentionAggregator {
+	return &AttentionAggregator{
+		Keys:  anynet.NewFC(c, latentSize, keySize),
+		Query: anynet.NewFCZero(c, latentSize, keySize),
+	}
+}
+
+// DeserializeAttentionAggregator deserializes an
+// AttentionAggregator.
+func DeserializeAttentionAggregator(d []byte) (*AttentionAggregator, error) {
+	var res AttentionAggregator
+	if err := serializer.DeserializeAny(d, &res.Keys, &res.Query); err != nil {
+		return nil, essentials.AddCtx("deserialize AttentionAggregator", err)
+	}
+	return &res, nil
+}
+
+// Aggregate computes the attention-weighted average of
+// the context vectors.
+func (a *AttentionAggregator) Aggregate(context anydiff.Res, n int,
+	candidate anydiff.Res) anydiff.Res {
+	latentSize := context.Output().Len() / n
+	keySize := a.Keys.OutCount
+	c := context.Output().Creator()
+	return anydiff.Pool(context, func(context anydiff.Res) anydiff.Res {
+		scores := anydiff.MatMul(false, false,
+			&anydiff.Matrix{Data: a.Keys.Apply(context, n), Rows: n, Cols: keySize},
+			&anydiff.Matrix{Data: a.Query.Apply(candidate, 1), Rows: keySize, Cols: 1},
+		).Data
+		scores = anydiff.Scale(scores, c.MakeNumeric(1/math.Sqrt(float64(keySize))))
+		weights := anydiff.Exp(anydiff.LogSoftmax(scores, n))
+		return anydiff.MatMul(false, false,
+			&anydiff.Matrix{Data: weights, Rows: 1, Cols: n},
+			&anydiff.Matrix{Data: context, Rows: n, Cols: latentSize},
+		).Data
+	})
+}
+
+// Parameters returns the projection parameters.
+func (a *AttentionAggregator) Parameters() []*anydiff.Var {
+	return append(a.Keys.Parameters(), a.Query.Parameters()...)
+}
+
+// SerializerType returns the unique ID used to serialize
+// an AttentionAggregator with the serializer package.
+func (a *AttentionAggregator) SerializerType() string {
+	return "github.com/unixpickle/tweeters.AttentionAggregator"
+}
+
+// Serialize serializes the AttentionAggregator.
+func (a *AttentionAggregator) Serialize() ([]byte, error) {
+	return serializer.SerializeAny(a.Keys, a.Query)
+}
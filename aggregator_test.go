@@ -0,0 +1,60 @@
+package tweeters
+
+import (
+	"math"
+	"testing"
+
+	"github.com/unixpickle/anydiff"
+	"github.com/unixpickle/anynet"
+	"github.com/unixpickle/anyvec/anyvec64"
+)
+
+func TestAttentionAggregatorInit(t *testing.T) {
+	c := anyvec64.CurrentCreator()
+	agg := NewAttentionAggregator(c, 4, 2)
+	context := anydiff.NewConst(c.MakeVectorData([]float64{1, 2, 3, 4, -1, 0, 1, 2}))
+	candidate := anydiff.NewConst(c.MakeVectorData([]float64{5, 6, 7, 8}))
+	actual := VectorFloats(agg.Aggregate(context, 2, candidate).Output())
+	expected := []float64{0, 1, 2, 3}
+	for i, x := range expected {
+		if math.Abs(actual[i]-x) > 1e-8 {
+			t.Fatalf("expected %v but got %v", expected, actual)
+		}
+	}
+}
+
+func TestAggregatorModel(t *testing.T) {
+	c := anyvec64.CurrentCreator()
+	arch := &Architecture{Cell: "lstm", Layers: 1, Hidden: 8, Pooling: "tail",
+		Aggregator: "attention", Dropout: 1}
+	model, err := arch.NewModel(c, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	agg := model.Aggregator.(*AttentionAggregator)
+	agg.Query = anynet.NewFC(c, 8, 2)
+
+	context := randomTweets(4)
+	candidates := randomTweets(3)
+	var expected []float64
+	for _, candidate := range candidates {
+		tweets := append(append([][]byte{}, context...), candidate)
+		logit := model.Classify(tweets, []int{len(context), 1})
+		expected = append(expected, VectorFloats(logit.Output())...)
+	}
+
+	actual := NewContext(model, context).Score(candidates, 2)
+	for i, x := range expected {
+		if math.Abs(actual[i]-x) > 1e-5 {
+			t.Errorf("candidate %d: expected %f but got %f", i, x, actual[i])
+		}
+	}
+
+	attributor := NewAttributor(model, [][][]byte{randomTweets(2), context})
+	for i, scores := range attributor.Scores(candidates) {
+		if math.Abs(scores[1]-expected[i]) > 1e-5 {
+			t.Errorf("candidate %d: expected attribution score %f but got %f", i,
+				expected[i], scores[1])
+		}
+	}
+}
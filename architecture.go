@@ -0,0 +1,293 @@
+package tweeters
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/unixpickle/anynet"
+	"github.com/unixpickle/anynet/anyrnn"
+	"github.com/unixpickle/anyvec"
+	"github.com/unixpickle/essentials"
+)
+
+// An Architecture describes the shape of a new Model.
+//
+// Architectures can be stored as JSON, where missing
+// fields take their values from DefaultArchitecture.
+type Architecture struct {
+	// Cell is the recurrent cell type.
+	// It may be "lstm", "gru", or "vanilla".
+	Cell string `json:"cell"`
+
+	// Layers is the number of recurrent layers.
+	// It may be 0 if there are convolutional layers.
+	Layers int `json:"layers"`
+
+	// Hidden is the output size of each recurrent and
+	// convolutional layer.
+	Hidden int `json:"hidden"`
+
+	// ConvLayers is the number of convolutional layers
+	// applied before the recurrent layers.
+	// See NewConvEncoder.
+	ConvLayers int `json:"conv_layers"`
+
+	// ConvWidth is the kernel width of every
+	// convolutional layer.
+	ConvWidth int `json:"conv_width"`
+
+	// EmbedDim is the size of the input embedding.
+	// If it is 0, the encoder reads one-hot vectors.
+	EmbedDim int `json:"embed"`
+
+	// Bidirectional adds a second encoder which reads
+	// every tweet backwards.
+	// The latent vector concatenates the pooled outputs of
+	// both directions.
+	Bidirectional bool `json:"bidirectional"`
+
+	// Pooling determines how the encoder's outputs are
+	// turned into a latent vector.
+	// It may be "tail", "mean", "max", or "attention".
+	// See NewPooler for details.
+	Pooling string `json:"pooling"`
+
+	// Aggregator determines how the context vectors are
+	// combined before being compared to a candidate.
+	// It may be "mean" or "attention".
+	// See NewAggregator for details.
+	Aggregator string `json:"aggregator"`
+
+	// Features is the classifier's feature mode, such as
+	// ConcatFeatures.
+	Features string `json:"features"`
+
+	// LMHead adds a next-token prediction head to the
+	// model for use with Model.LanguageModelCost.
+	LMHead bool `json:"lm_head"`
+
+	// Residual adds each layer's input to its output,
+	// for every layer whose input size matches Hidden.
+	Residual bool `json:"residual"`
+
+	// LayerNorm normalizes the output of every recurrent
+	// layer.
+	LayerNorm bool `json:"layer_norm"`
+
+	// Dropout is the keep probability for dropout after
+	// every recurrent layer.
+	Dropout float64 `json:"dropout"`
+
+	// Classifier lists the sizes of the classifier's
+	// hidden layers.
+	Classifier []int `json:"classifier"`
+
+	// MaxLen, if non-zero, is the maximum number of bytes
+	// of each tweet that the model reads.
+	MaxLen int `json:"max_len"`
+
+	// Truncation is the strategy for shortening tweets
+	// longer than MaxLen, such as HeadTruncation.
+	Truncation string `json:"truncation"`
+}
+
+// DefaultArchitecture returns the architecture used by
+// NewModel.
+func DefaultArchitecture() *Architecture {
+	return &Architecture{
+		Cell:       "lstm",
+		Layers:     3,
+		Hidden:     512,
+		ConvWidth:  3,
+		Pooling:    "tail",
+		Aggregator: "mean",
+		Features:   ConcatFeatures,
+		Dropout:    1,
+		Classifier: []int{0x200, 0x100},
+		Truncation: HeadTruncation,
+	}
+}
+
+// LoadArchitecture reads a JSON architecture file.
+func LoadArchitecture(path string) (arch *Architecture, err error) {
+	defer essentials.AddCtxTo("load architecture", &err)
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	arch = DefaultArchitecture()
+	if err := json.Unmarshal(data, arch); err != nil {
+		return nil, err
+	}
+	return arch, nil
+}
+
+// LatentSize returns the size of the latent vectors
+// produced by the architecture's encoder.
+func (a *Architecture) LatentSize() int {
+	if a.Bidirectional {
+		return a.Hidden * 2
+	}
+	return a.Hidden
+}
+
+// Validate checks that the architecture is usable.
+func (a *Architecture) Validate() error {
+	switch a.Cell {
+	case "lstm", "gru", "vanilla":
+	default:
+		return errors.New("unknown cell type: " + a.Cell)
+	}
+	switch a.Pooling {
+	case "", "tail", "mean", "max", "attention":
+	default:
+		return errors.New("unknown pooling: " + a.Pooling)
+	}
+	switch a.Aggregator {
+	case "", "mean", "attention":
+	default:
+		return errors.New("unknown aggregator: " + a.Aggregator)
+	}
+	if _, err := FeatureSize(a.Features, a.LatentSize()); err != nil {
+		return err
+	}
+	if a.Layers < 0 || a.ConvLayers < 0 || a.Layers+a.ConvLayers == 0 {
+		return errors.New("at least one layer is required")
+	}
+	if a.LMHead && (a.Layers == 0 || a.ConvLayers > 0) {
+		return errors.New("language model heads require a purely recurrent encoder")
+	}
+	if a.Layers == 0 && (a.Bidirectional || a.Pooling == "" || a.Pooling == "tail") {
+		return errors.New("convolutional encoders must not be bidirectional " +
+			"or use tail pooling")
+	}
+	if a.Hidden < 1 || a.EmbedDim < 0 || (a.ConvLayers > 0 && a.ConvWidth < 1) {
+		return errors.New("invalid layer size")
+	}
+	if a.Dropout <= 0 || a.Dropout > 1 {
+		return fmt.Errorf("invalid dropout keep probability: %f", a.Dropout)
+	}
+	for _, size := range a.Classifier {
+		if size < 1 {
+			return errors.New("invalid classifier layer size")
+		}
+	}
+	if _, err := NewTruncation(a.MaxLen, a.Truncation); err != nil {
+		return err
+	}
+	return nil
+}
+
+// NewModel creates a randomly-initialized model with the
+// architecture.
+//
+// If tok is nil, the model uses a ByteTokenizer.
+func (a *Architecture) NewModel(c anyvec.Creator, tok Tokenizer) (*Model, error) {
+	if err := a.Validate(); err != nil {
+		return nil, err
+	}
+	if tok == nil {
+		tok = &ByteTokenizer{}
+	}
+	truncation, err := NewTruncation(a.MaxLen, a.Truncation)
+	if err != nil {
+		return nil, err
+	}
+	res := &Model{Tokenizer: tok, Features: a.Features, Truncation: truncation}
+	inSize := tok.VocabSize()
+	if a.EmbedDim != 0 {
+		res.Embedding = NewEmbedding(c, tok.VocabSize(), a.EmbedDim)
+		inSize = a.EmbedDim
+	}
+	if a.ConvLayers > 0 {
+		res.Conv = NewConvEncoder(c, inSize, a.Hidden, a.ConvWidth, a.ConvLayers)
+	}
+	res.Encoder = a.newEncoder(c, inSize)
+	if a.Bidirectional {
+		res.Backward = a.newEncoder(c, inSize)
+	}
+	pooler, err := NewPooler(c, a.Pooling, a.Hidden)
+	if err != nil {
+		return nil, err
+	}
+	res.Pooler = pooler
+	aggregator, err := NewAggregator(c, a.Aggregator, a.LatentSize())
+	if err != nil {
+		return nil, err
+	}
+	res.Aggregator = aggregator
+	if a.LMHead {
+		res.LMHead = anynet.NewFCZero(c, a.Hidden, tok.VocabSize())
+	}
+
+	inSize, err = FeatureSize(a.Features, a.LatentSize())
+	if err != nil {
+		return nil, err
+	}
+	res.Classifier = NewClassifier(c, inSize, a.Classifier)
+
+	return res, nil
+}
+
+// NewClassifier creates a classifier network with the
+// given input size and hidden layer sizes.
+//
+// The output layer is initialized to zero.
+func NewClassifier(c anyvec.Creator, inSize int, hidden []int) anynet.Net {
+	var res anynet.Net
+	for _, size := range hidden {
+		res = append(res, anynet.NewFC(c, inSize, size), anynet.Tanh)
+		inSize = size
+	}
+	return append(res, anynet.NewFCZero(c, inSize, 1))
+}
+
+func (a *Architecture) newEncoder(c anyvec.Creator, inSize int) anyrnn.Stack {
+	res := anyrnn.Stack{}
+	for i := 0; i < a.Layers; i++ {
+		rawInput := i == 0 && a.ConvLayers == 0
+		if !rawInput {
+			inSize = a.Hidden
+		}
+		layer := anyrnn.Stack{a.newCell(c, inSize, rawInput)}
+		if a.LayerNorm {
+			layer = append(layer, &anyrnn.LayerBlock{Layer: NewLayerNorm(c, a.Hidden)})
+		}
+		var block anyrnn.Block = layer
+		if len(layer) == 1 {
+			block = layer[0]
+		}
+		if a.Residual && inSize == a.Hidden {
+			block = &Residual{Block: block}
+		}
+		res = append(res, block, &anyrnn.LayerBlock{
+			Layer: &anynet.Dropout{Enabled: false, KeepProb: a.Dropout},
+		})
+	}
+	return res
+}
+
+func (a *Architecture) newCell(c anyvec.Creator, inSize int, rawInput bool) anyrnn.Block {
+	// One-hot inputs have a tiny magnitude compared to the
+	// hidden states, so their weights are scaled up.
+	inScale := 2.0
+	if rawInput {
+		inScale = 1
+		if a.EmbedDim == 0 {
+			inScale = 0x10
+		}
+	}
+	switch a.Cell {
+	case "lstm":
+		return anyrnn.NewLSTM(c, inSize, a.Hidden).ScaleInWeights(c.MakeNumeric(inScale))
+	case "gru":
+		return anyrnn.NewGRU(c, inSize, a.Hidden)
+	case "vanilla":
+		return anyrnn.NewVanilla(c, inSize, a.Hidden, anynet.Tanh).
+			ScaleInWeights(c.MakeNumeric(inScale))
+	default:
+		panic("unknown cell type: " + a.Cell)
+	}
+}
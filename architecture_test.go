@@ -0,0 +1,135 @@
+package tweeters
+
+import (
+	"io/ioutil"
+	"math"
+	"os"
+	"testing"
+
+	"github.com/unixpickle/anydiff"
+	"github.com/unixpickle/anynet"
+	"github.com/unixpickle/anynet/anyrnn"
+	"github.com/unixpickle/anyvec/anyvec64"
+)
+
+func TestArchitectureNewModel(t *testing.T) {
+	c := anyvec64.CurrentCreator()
+	for _, arch := range []*Architecture{
+		{Cell: "lstm", Layers: 2, Hidden: 8, Dropout: 1, Classifier: []int{5}},
+		{Cell: "gru", Layers: 3, Hidden: 8, EmbedDim: 4, Residual: true, Dropout: 0.9},
+		{Cell: "vanilla", Layers: 2, Hidden: 6, LayerNorm: true, Bidirectional: true,
+			Dropout: 1, Classifier: []int{7, 3}},
+	} {
+		model, err := arch.NewModel(c, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tweets := randomTweets(3)
+		latent := model.Encode(tweets)
+		if latent.Output().Len() != 3*arch.LatentSize() {
+			t.Errorf("%s: expected latent size %d but got %d", arch.Cell,
+				3*arch.LatentSize(), latent.Output().Len())
+		}
+		logits := model.Classifier.Apply(model.Averages(tweets, []int{2, 1}), 1)
+		if logits.Output().Len() != 1 {
+			t.Errorf("%s: unexpected classifier output size", arch.Cell)
+		}
+		checkRoundTrip(t, model, tweets)
+	}
+}
+
+func TestArchitectureValidate(t *testing.T) {
+	arch := DefaultArchitecture()
+	arch.Cell = "transformer"
+	if arch.Validate() == nil {
+		t.Error("expected error for unknown cell")
+	}
+	arch = DefaultArchitecture()
+	arch.Layers = 0
+	if arch.Validate() == nil {
+		t.Error("expected error for zero layers")
+	}
+}
+
+func TestLoadArchitecture(t *testing.T) {
+	f, err := ioutil.TempFile("", "archtest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+	data := `{"cell": "gru", "bidirectional": true, "classifier": [16]}`
+	if _, err := f.WriteString(data); err != nil {
+		t.Fatal(err)
+	}
+	arch, err := LoadArchitecture(f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if arch.Cell != "gru" || !arch.Bidirectional || len(arch.Classifier) != 1 {
+		t.Errorf("unexpected architecture: %+v", arch)
+	}
+	if arch.Layers != DefaultArchitecture().Layers {
+		t.Error("missing field should have default value")
+	}
+}
+
+func TestLayerNorm(t *testing.T) {
+	c := anyvec64.CurrentCreator()
+	layer := NewLayerNorm(c, 4)
+	in := anydiff.NewConst(c.MakeVectorData([]float64{1, 2, 3, 4, -5, 0, 5, 10}))
+	out := VectorFloats(layer.Apply(in, 2).Output())
+	for row := 0; row < 2; row++ {
+		var mean, variance float64
+		for _, x := range out[row*4 : (row+1)*4] {
+			mean += x / 4
+			variance += x * x / 4
+		}
+		if math.Abs(mean) > 1e-5 || math.Abs(variance-1) > 1e-3 {
+			t.Errorf("row %d: mean %f, variance %f", row, mean, variance)
+		}
+	}
+}
+
+func TestConcatRows(t *testing.T) {
+	c := anyvec64.CurrentCreator()
+	a := anydiff.NewConst(c.MakeVectorData([]float64{1, 2, 3, 4}))
+	b := anydiff.NewConst(c.MakeVectorData([]float64{5, 6}))
+	actual := VectorFloats(concatRows(a, b, 2).Output())
+	expected := []float64{1, 2, 5, 3, 4, 6}
+	for i, x := range expected {
+		if actual[i] != x {
+			t.Fatalf("expected %v but got %v", expected, actual)
+		}
+	}
+}
+
+func TestFrozen(t *testing.T) {
+	c := anyvec64.CurrentCreator()
+	arch := &Architecture{Cell: "lstm", Layers: 2, Hidden: 8, Dropout: 0.5}
+	model, err := arch.NewModel(c, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	encoder := model.Encoder.(anyrnn.Stack)
+	frozenParams := anynet.AllParameters(encoder[0])
+	encoder[0] = &Frozen{Block: encoder[0]}
+
+	trainable := anydiff.NewVarSet(model.TrainableParameters()...)
+	for _, p := range frozenParams {
+		if trainable.Has(p) {
+			t.Fatal("frozen parameter is trainable")
+		}
+	}
+	if len(model.TrainableParameters())+len(frozenParams) != len(model.Parameters()) {
+		t.Error("unexpected number of trainable parameters")
+	}
+	if len(model.Dropouts()) != 2 {
+		t.Errorf("expected 2 dropouts but got %d", len(model.Dropouts()))
+	}
+
+	decoded := checkRoundTrip(t, model, randomTweets(3))
+	if _, ok := decoded.Encoder.(anyrnn.Stack)[0].(*Frozen); !ok {
+		t.Error("frozen block was not preserved")
+	}
+}
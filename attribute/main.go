@@ -0,0 +1,93 @@
+// Command attribute evaluates a model on closed-set
+// author attribution.
+//
+// For each round, a set of users is drawn from the
+// testing partition.
+// A few tweets from every user are held out, and the rest
+// are used to build user prototypes.
+// Each held-out tweet is then attributed to one of the
+// users in the set.
+package main
+
+import (
+	"flag"
+	"log"
+	"math/rand"
+	"time"
+
+	"github.com/unixpickle/essentials"
+	"github.com/unixpickle/serializer"
+	"github.com/unixpickle/tweeters"
+)
+
+func main() {
+	rand.Seed(time.Now().UnixNano())
+
+	var modelPath string
+	var dbPath string
+	var validation float64
+	var numUsers int
+	var numHeld int
+	var minTweets, maxTweets int
+	flag.StringVar(&modelPath, "model", "../train/model_out", "path to trained model")
+	flag.StringVar(&dbPath, "data", "", "path to tweet DB")
+	flag.Float64Var(&validation, "validation", 0.1, "validation fraction used to train")
+	flag.IntVar(&numUsers, "users", 20, "number of candidate users per round")
+	flag.IntVar(&numHeld, "held", 1, "held-out tweets per user")
+	flag.IntVar(&minTweets, "min", 2, "minimum known tweets per user")
+	flag.IntVar(&maxTweets, "max", 16, "maximum known tweets per user")
+	flag.Parse()
+
+	if dbPath == "" {
+		essentials.Die("Required flag: -data. See -help.")
+	}
+
+	log.Println("Loading model...")
+	var model *tweeters.Model
+	if err := serializer.LoadAny(modelPath, &model); err != nil {
+		essentials.Die(err)
+	}
+	model.SetDropout(false)
+
+	log.Println("Loading DB...")
+	db, err := tweeters.OpenDB(dbPath)
+	if err != nil {
+		essentials.Die(err)
+	}
+	samples := tweeters.NewSamples(db)
+	samples.Truncation = model.Truncation
+	_, testing := samples.Partition(validation)
+	log.Printf("%d testing users", len(testing.UserIndices))
+
+	log.Println("Computing attribution metrics...")
+	var stats tweeters.AttributionStats
+	for {
+		users, err := testing.RandomUsers(numUsers, minTweets+numHeld)
+		if err != nil {
+			essentials.Die(err)
+		}
+		if len(users) < 2 {
+			essentials.Die("not enough users with enough tweets")
+		}
+
+		var histories [][][]byte
+		var heldOut [][]byte
+		var authors []int
+		for i, tweets := range users {
+			heldOut = append(heldOut, tweets[:numHeld]...)
+			for j := 0; j < numHeld; j++ {
+				authors = append(authors, i)
+			}
+			known := tweets[numHeld:]
+			histories = append(histories, known[:essentials.MinInt(len(known), maxTweets)])
+		}
+
+		attributor := tweeters.NewAttributor(model, histories)
+		for i, ranking := range attributor.Rank(heldOut) {
+			stats.Add(ranking, authors[i])
+		}
+		log.Printf("top1=%.2f%% top5=%.2f%% mrr=%.4f (out of %d, %d users)",
+			100*stats.Top1Accuracy(), 100*stats.Top5Accuracy(), stats.MRR(),
+			stats.Total, len(users))
+	}
+}
@@ -0,0 +1,181 @@
+package tweeters
+
+import (
+	"sort"
+
+	"github.com/unixpickle/anydiff"
+	"github.com/unixpickle/anyvec"
+)
+
+// An Attributor performs closed-set author attribution.
+//
+// Each candidate user is represented by a prototype,
+// which is the average latent vector of that user's known
+// tweets.
+// Anonymous tweets are attributed by comparing them to
+// every prototype with the model's classifier.
+type Attributor struct {
+	Model *Model
+
+	// Prototypes contains one packed latent vector per
+	// candidate user.
+	Prototypes anyvec.Vector
+
+	// Histories contains the packed latent vectors of the
+	// known tweets of each candidate user.
+	// It is used for models with an Aggregator.
+	Histories []anyvec.Vector
+
+	// NumUsers is the number of candidate users.
+	NumUsers int
+}
+
+// NewAttributor creates an Attributor with a prototype
+// for each of the given users.
+//
+// Each entry of histories lists the known tweets for one
+// candidate user.
+// Every user must have at least one known tweet.
+func NewAttributor(m *Model, histories [][][]byte) *Attributor {
+	var tweets [][]byte
+	var sizes []int
+	for _, history := range histories {
+		if len(history) == 0 {
+			panic("every user needs at least one tweet")
+		}
+		tweets = append(tweets, history...)
+		sizes = append(sizes, len(history))
+	}
+	latent := m.Encode(tweets)
+	res := &Attributor{
+		Model:      m,
+		Prototypes: averageLatent(latent, len(tweets), sizes).Output(),
+		NumUsers:   len(histories),
+	}
+	latentSize := latent.Output().Len() / len(tweets)
+	var offset int
+	for _, size := range sizes {
+		history := latent.Output().Slice(offset*latentSize, (offset+size)*latentSize)
+		res.Histories = append(res.Histories, history)
+		offset += size
+	}
+	return res
+}
+
+// Scores computes, for each tweet, the classifier's
+// same-author logit against every candidate user.
+//
+// The result is indexed first by tweet, then by user.
+func (a *Attributor) Scores(tweets [][]byte) [][]float64 {
+	latent := a.Model.Encode(tweets).Output()
+	latentSize := a.Prototypes.Len() / a.NumUsers
+
+	var contexts, candidates []anyvec.Vector
+	var sizes []int
+	for i := range tweets {
+		tweetVec := latent.Slice(i*latentSize, (i+1)*latentSize)
+		for j := 0; j < a.NumUsers; j++ {
+			if a.Model.Aggregator == nil {
+				contexts = append(contexts, a.Prototypes.Slice(j*latentSize, (j+1)*latentSize))
+				sizes = append(sizes, 1)
+			} else {
+				contexts = append(contexts, a.Histories[j])
+				sizes = append(sizes, a.Histories[j].Len()/latentSize)
+			}
+			candidates = append(candidates, tweetVec)
+		}
+	}
+	c := latent.Creator()
+	out := VectorFloats(a.Model.compare(anydiff.NewConst(c.Concat(contexts...)), sizes,
+		anydiff.NewConst(c.Concat(candidates...))).Output())
+
+	res := make([][]float64, len(tweets))
+	for i := range res {
+		res[i] = out[i*a.NumUsers : (i+1)*a.NumUsers]
+	}
+	return res
+}
+
+// Rank sorts the candidate users for each tweet from most
+// to least likely author.
+//
+// The result is indexed first by tweet, then by rank.
+func (a *Attributor) Rank(tweets [][]byte) [][]int {
+	var res [][]int
+	for _, scores := range a.Scores(tweets) {
+		ranking := make([]int, len(scores))
+		for i := range ranking {
+			ranking[i] = i
+		}
+		sort.SliceStable(ranking, func(i, j int) bool {
+			return scores[ranking[i]] > scores[ranking[j]]
+		})
+		res = append(res, ranking)
+	}
+	return res
+}
+
+// AttributionStats accumulates closed-set attribution
+// metrics.
+type AttributionStats struct {
+	Total     int
+	Top1      int
+	Top5      int
+	RecipRank float64
+}
+
+// Add records the ranking for a tweet whose true author
+// has the given candidate index.
+func (a *AttributionStats) Add(ranking []int, author int) {
+	for i, user := range ranking {
+		if user == author {
+			if i == 0 {
+				a.Top1++
+			}
+			if i < 5 {
+				a.Top5++
+			}
+			a.RecipRank += 1 / float64(i+1)
+			break
+		}
+	}
+	a.Total++
+}
+
+// Top1Accuracy returns the fraction of tweets whose true
+// author was ranked first.
+func (a *AttributionStats) Top1Accuracy() float64 {
+	return float64(a.Top1) / float64(a.Total)
+}
+
+// Top5Accuracy returns the fraction of tweets whose true
+// author was ranked in the top five.
+func (a *AttributionStats) Top5Accuracy() float64 {
+	return float64(a.Top5) / float64(a.Total)
+}
+
+// MRR returns the mean reciprocal rank of the true
+// authors.
+func (a *AttributionStats) MRR() float64 {
+	return a.RecipRank / float64(a.Total)
+}
+
+// VectorFloats returns the contents of a float32 or
+// float64 vector as float64 values.
+//
+// For float64 vectors, the result shares the vector's
+// data and should not be modified.
+func VectorFloats(v anyvec.Vector) []float64 {
+	switch data := v.Data().(type) {
+	case []float32:
+		res := make([]float64, len(data))
+		for i, x := range data {
+			res[i] = float64(x)
+		}
+		return res
+	case []float64:
+		return data
+	default:
+		panic("unsupported numeric type")
+	}
+}
@@ -0,0 +1,27 @@
+package tweeters
+
+import (
+	"math"
+	"testing"
+)
+
+func TestAttributionStats(t *testing.T) {
+	var stats AttributionStats
+	stats.Add([]int{2, 0, 1, 3, 4, 5, 6}, 2)
+	stats.Add([]int{2, 0, 1, 3, 4, 5, 6}, 1)
+	stats.Add([]int{2, 0, 1, 3, 4, 5, 6}, 6)
+
+	if stats.Total != 3 {
+		t.Errorf("expected total 3 but got %d", stats.Total)
+	}
+	if actual := stats.Top1Accuracy(); math.Abs(actual-1.0/3) > 1e-8 {
+		t.Errorf("expected top1 %f but got %f", 1.0/3, actual)
+	}
+	if actual := stats.Top5Accuracy(); math.Abs(actual-2.0/3) > 1e-8 {
+		t.Errorf("expected top5 %f but got %f", 2.0/3, actual)
+	}
+	expectedMRR := (1 + 1.0/3 + 1.0/7) / 3
+	if actual := stats.MRR(); math.Abs(actual-expectedMRR) > 1e-8 {
+		t.Errorf("expected MRR %f but got %f", expectedMRR, actual)
+	}
+}
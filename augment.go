@@ -0,0 +1,129 @@
+package tweeters
+
+import (
+	"math/rand"
+	"regexp"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Placeholders which replace URLs and mentions during
+// augmentation.
+const (
+	URLPlaceholder     = "http://url"
+	MentionPlaceholder = "@user"
+)
+
+var (
+	urlExpr     = regexp.MustCompile(`https?://[^\s]+`)
+	mentionExpr = regexp.MustCompile(`@[A-Za-z0-9_]+`)
+)
+
+// Augmentation randomly perturbs tweets during training,
+// making it harder for a model to rely on surface-level
+// quirks.
+//
+// Every field is a probability, and a zero field disables
+// the corresponding augmentation.
+type Augmentation struct {
+	// CharDropout is the probability of deleting each
+	// character.
+	CharDropout float64
+
+	// CaseFlip is the probability of flipping the case of
+	// each letter.
+	CaseFlip float64
+
+	// Typo is the probability of introducing a typo at
+	// each character, either by replacing it with a random
+	// letter, repeating it, or swapping it with the next
+	// character.
+	Typo float64
+
+	// URLs is the probability of replacing each URL with
+	// URLPlaceholder.
+	URLs float64
+
+	// Mentions is the probability of replacing each
+	// mention with MentionPlaceholder.
+	Mentions float64
+
+	// Emoji is the probability of deleting each emoji.
+	Emoji float64
+}
+
+// Augment produces a randomly perturbed version of a
+// tweet, leaving the original tweet unmodified.
+//
+// If deleting characters or emoji would leave nothing of
+// the tweet, the character-level perturbations are undone,
+// since an empty tweet has no latent vector under some
+// poolers.
+func (a *Augmentation) Augment(tweet []byte) []byte {
+	if a.URLs > 0 {
+		tweet = replaceMatches(urlExpr, tweet, URLPlaceholder, a.URLs)
+	}
+	if a.Mentions > 0 {
+		tweet = replaceMatches(mentionExpr, tweet, MentionPlaceholder, a.Mentions)
+	}
+	if a.CharDropout == 0 && a.CaseFlip == 0 && a.Typo == 0 && a.Emoji == 0 {
+		return tweet
+	}
+
+	original := tweet
+	var res []byte
+	for len(tweet) > 0 {
+		r, size := utf8.DecodeRune(tweet)
+		char := tweet[:size]
+		tweet = tweet[size:]
+		if r == utf8.RuneError && size == 1 {
+			// Invalid UTF-8 is passed through untouched.
+			res = append(res, char...)
+			continue
+		}
+		if a.Emoji > 0 && IsEmoji(r) && rand.Float64() < a.Emoji {
+			continue
+		}
+		if rand.Float64() < a.CharDropout {
+			continue
+		}
+		if unicode.IsLetter(r) && rand.Float64() < a.CaseFlip {
+			if unicode.IsUpper(r) {
+				char = []byte(string(unicode.ToLower(r)))
+			} else {
+				char = []byte(string(unicode.ToUpper(r)))
+			}
+		}
+		if rand.Float64() < a.Typo {
+			switch rand.Intn(3) {
+			case 0:
+				char = []byte{byte('a' + rand.Intn(26))}
+			case 1:
+				res = append(res, char...)
+			case 2:
+				if len(tweet) > 0 {
+					_, nextSize := utf8.DecodeRune(tweet)
+					res = append(res, tweet[:nextSize]...)
+					tweet = tweet[nextSize:]
+				}
+			}
+		}
+		res = append(res, char...)
+	}
+	if len(res) == 0 {
+		return original
+	}
+	return res
+}
+
+// replaceMatches replaces each match of expr with the
+// replacement with probability prob.
+func replaceMatches(expr *regexp.Regexp, tweet []byte, replacement string,
+	prob float64) []byte {
+	return expr.ReplaceAllFunc(tweet, func(match []byte) []byte {
+		if rand.Float64() < prob {
+			return []byte(replacement)
+		}
+		return match
+	})
+}
@@ -0,0 +1,60 @@
+package tweeters
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/unixpickle/anyvec/anyvec64"
+)
+
+func TestAugmentation(t *testing.T) {
+	tests := []struct {
+		aug      Augmentation
+		in       string
+		expected string
+	}{
+		{Augmentation{}, "Hello @bob 😀", "Hello @bob 😀"},
+		{Augmentation{URLs: 1}, "see https://t.co/abc now", "see http://url now"},
+		{Augmentation{Mentions: 1}, "@bob hi @alice_1!", "@user hi @user!"},
+		{Augmentation{Emoji: 1}, "hi 😀❤️!", "hi !"},
+		{Augmentation{Emoji: 1}, "😀", "😀"},
+		{Augmentation{CharDropout: 1}, "hello", "hello"},
+		{Augmentation{CharDropout: 1, URLs: 1}, "https://t.co/abc", URLPlaceholder},
+		{Augmentation{CaseFlip: 1}, "aB1é", "Ab1É"},
+		{Augmentation{CaseFlip: 1}, "a\xffB\xe2\x82", "A\xffb\xe2\x82"},
+		{Augmentation{CharDropout: 1}, "hi\xff", "\xff"},
+	}
+	for _, test := range tests {
+		actual := string(test.aug.Augment([]byte(test.in)))
+		if actual != test.expected {
+			t.Errorf("%+v: expected %q but got %q", test.aug, test.expected, actual)
+		}
+	}
+
+	aug := &Augmentation{Typo: 1}
+	for i := 0; i < 10; i++ {
+		out := aug.Augment([]byte("abcdefgh"))
+		if len(out) < 8 || len(out) > 16 {
+			t.Errorf("unexpected typo output: %q", out)
+		}
+		out = aug.Augment([]byte("ab\xffcd"))
+		if !bytes.Contains(out, []byte{0xff}) || bytes.Contains(out, []byte("\ufffd")) {
+			t.Errorf("invalid byte was not preserved: %q", out)
+		}
+	}
+}
+
+func TestAugmentationEncode(t *testing.T) {
+	model := NewModel(anyvec64.CurrentCreator(), nil, 0, 16, 1)
+	if model.Pooler != nil {
+		t.Fatal("expected tail pooling")
+	}
+	aug := &Augmentation{CharDropout: 1}
+	tweets := randomTweets(4)
+	for i, tweet := range tweets {
+		tweets[i] = aug.Augment(tweet)
+	}
+	if actual := model.Encode(tweets).Output().Len(); actual != 4*16 {
+		t.Errorf("expected %d latent components but got %d", 4*16, actual)
+	}
+}
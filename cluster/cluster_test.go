@@ -0,0 +1,42 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestKMeans(t *testing.T) {
+	vecs := [][]float64{
+		{0, 0}, {0.1, 0}, {0, 0.1},
+		{10, 10}, {10.1, 10}, {10, 10.1},
+	}
+	kmeans := &KMeans{NumClusters: 2, Iters: 20}
+	for trial := 0; trial < 10; trial++ {
+		_, assignments := kmeans.Cluster(vecs)
+		for i := 1; i < 3; i++ {
+			if assignments[i] != assignments[0] || assignments[i+3] != assignments[3] {
+				t.Fatalf("unexpected assignments: %v", assignments)
+			}
+		}
+		if assignments[0] == assignments[3] {
+			t.Fatalf("unexpected assignments: %v", assignments)
+		}
+	}
+}
+
+func TestDistinctive(t *testing.T) {
+	background := NewTokenCounts()
+	cluster := NewTokenCounts()
+	for _, tweet := range []string{"Go Lakers!", "the lakers won", "the game was fun"} {
+		cluster.Add([]byte(tweet))
+		background.Add([]byte(tweet))
+	}
+	for _, tweet := range []string{"the cat", "the dog", "my cat was fun"} {
+		background.Add([]byte(tweet))
+	}
+	actual := cluster.Distinctive(background, 2, 2)
+	expected := []string{"lakers", "the"}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %v but got %v", expected, actual)
+	}
+}
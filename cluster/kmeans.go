@@ -0,0 +1,121 @@
+package main
+
+import (
+	"math"
+	"math/rand"
+)
+
+// KMeans clusters vectors with Lloyd's algorithm.
+type KMeans struct {
+	NumClusters int
+	Iters       int
+
+	// Spherical enables spherical k-means, which clusters
+	// unit vectors by cosine similarity.
+	Spherical bool
+}
+
+// Cluster computes cluster centers and an assignment for
+// each vector.
+//
+// In spherical mode, the vectors are normalized in place.
+func (k *KMeans) Cluster(vecs [][]float64) (centers [][]float64, assignments []int) {
+	if k.Spherical {
+		for _, v := range vecs {
+			normalize(v)
+		}
+	}
+	for _, i := range rand.Perm(len(vecs))[:k.NumClusters] {
+		centers = append(centers, append([]float64{}, vecs[i]...))
+	}
+	assignments = make([]int, len(vecs))
+	for iter := 0; iter < k.Iters; iter++ {
+		changed := false
+		for i, v := range vecs {
+			closest := k.Closest(centers, v)
+			if closest != assignments[i] || iter == 0 {
+				changed = true
+			}
+			assignments[i] = closest
+		}
+		if !changed {
+			break
+		}
+		centers = k.updateCenters(centers, vecs, assignments)
+	}
+	return
+}
+
+// Closest finds the index of the center nearest to v.
+func (k *KMeans) Closest(centers [][]float64, v []float64) int {
+	var best int
+	var bestScore float64
+	for i, center := range centers {
+		score := k.Similarity(center, v)
+		if i == 0 || score > bestScore {
+			best = i
+			bestScore = score
+		}
+	}
+	return best
+}
+
+// Similarity measures how close a vector is to a center.
+//
+// For regular k-means, this is the negative squared
+// Euclidean distance.
+// For spherical k-means, it is the dot product.
+func (k *KMeans) Similarity(center, v []float64) float64 {
+	var res float64
+	for i, x := range v {
+		if k.Spherical {
+			res += x * center[i]
+		} else {
+			res -= (x - center[i]) * (x - center[i])
+		}
+	}
+	return res
+}
+
+func (k *KMeans) updateCenters(old, vecs [][]float64, assignments []int) [][]float64 {
+	counts := make([]int, len(old))
+	centers := make([][]float64, len(old))
+	for i := range centers {
+		centers[i] = make([]float64, len(old[i]))
+	}
+	for i, v := range vecs {
+		cluster := assignments[i]
+		counts[cluster]++
+		for j, x := range v {
+			centers[cluster][j] += x
+		}
+	}
+	for i, center := range centers {
+		if counts[i] == 0 {
+			// Keep empty clusters where they were.
+			centers[i] = old[i]
+			continue
+		}
+		for j := range center {
+			center[j] /= float64(counts[i])
+		}
+		if k.Spherical {
+			normalize(center)
+		}
+	}
+	return centers
+}
+
+func normalize(v []float64) {
+	var norm float64
+	for _, x := range v {
+		norm += x * x
+	}
+	if norm == 0 {
+		return
+	}
+	scale := 1 / math.Sqrt(norm)
+	for i := range v {
+		v[i] *= scale
+	}
+}
@@ -0,0 +1,229 @@
+// Command cluster groups users by their latent vectors to
+// discover common topics.
+//
+// Each user is represented by the average latent vector
+// of their tweets.
+// For every cluster, the command prints the tweets that
+// are closest to the cluster center and the words that
+// are most over-represented in the cluster.
+package main
+
+import (
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"log"
+	"math/rand"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/unixpickle/anyvec"
+	"github.com/unixpickle/essentials"
+	"github.com/unixpickle/serializer"
+	"github.com/unixpickle/tweeters"
+)
+
+// A User is a user which is being clustered.
+type User struct {
+	Name   string
+	Tweets [][]byte
+	Vector []float64
+}
+
+func main() {
+	rand.Seed(time.Now().UnixNano())
+
+	var modelPath string
+	var dbPath string
+	var outPath string
+	var numUsers int
+	var minTweets, maxTweets int
+	var batchSize int
+	var kmeans KMeans
+	var numTweets, numWords, minCount int
+	flag.StringVar(&modelPath, "model", "../train/model_out", "path to trained model")
+	flag.StringVar(&dbPath, "data", "", "path to tweet DB")
+	flag.StringVar(&outPath, "out", "", "optional CSV file for cluster assignments")
+	flag.IntVar(&numUsers, "users", 1000, "number of users to cluster")
+	flag.IntVar(&minTweets, "min", 2, "minimum tweets per user")
+	flag.IntVar(&maxTweets, "max", 16, "maximum tweets per user")
+	flag.IntVar(&batchSize, "batch", 256, "maximum tweets per encoder batch")
+	flag.IntVar(&kmeans.NumClusters, "k", 10, "number of clusters")
+	flag.IntVar(&kmeans.Iters, "iters", 50, "maximum k-means iterations")
+	flag.BoolVar(&kmeans.Spherical, "spherical", false, "use spherical k-means")
+	flag.IntVar(&numTweets, "tweets", 5, "characteristic tweets to print per cluster")
+	flag.IntVar(&numWords, "words", 10, "distinctive words to print per cluster")
+	flag.IntVar(&minCount, "mincount", 3, "minimum occurrences for distinctive words")
+	flag.Parse()
+
+	if dbPath == "" {
+		essentials.Die("Required flag: -data. See -help.")
+	}
+
+	log.Println("Loading model...")
+	var model *tweeters.Model
+	if err := serializer.LoadAny(modelPath, &model); err != nil {
+		essentials.Die(err)
+	}
+	model.SetDropout(false)
+
+	log.Println("Loading DB...")
+	db, err := tweeters.OpenDB(dbPath)
+	if err != nil {
+		essentials.Die(err)
+	}
+	defer db.Close()
+
+	log.Println("Selecting users...")
+	users, err := selectUsers(db, numUsers, minTweets, maxTweets)
+	if err != nil {
+		essentials.Die(err)
+	}
+	if len(users) < kmeans.NumClusters {
+		essentials.Die("not enough users for", kmeans.NumClusters, "clusters")
+	}
+
+	log.Printf("Embedding %d users...", len(users))
+	embedUsers(model, users, batchSize)
+
+	log.Println("Clustering...")
+	vecs := make([][]float64, len(users))
+	for i, user := range users {
+		vecs[i] = user.Vector
+	}
+	centers, assignments := kmeans.Cluster(vecs)
+
+	if outPath != "" {
+		log.Println("Writing assignments...")
+		if err := writeAssignments(outPath, users, assignments); err != nil {
+			essentials.Die(err)
+		}
+	}
+
+	background := NewTokenCounts()
+	for _, user := range users {
+		for _, tweet := range user.Tweets {
+			background.Add(tweet)
+		}
+	}
+
+	for cluster, center := range centers {
+		var tweets [][]byte
+		counts := NewTokenCounts()
+		var size int
+		for i, user := range users {
+			if assignments[i] == cluster {
+				size++
+				tweets = append(tweets, user.Tweets...)
+				for _, tweet := range user.Tweets {
+					counts.Add(tweet)
+				}
+			}
+		}
+		fmt.Printf("Cluster %d (%d users)\n", cluster, size)
+		words := counts.Distinctive(background, numWords, minCount)
+		fmt.Printf("  words: %s\n", strings.Join(words, ", "))
+		for _, tweet := range characteristicTweets(model, &kmeans, center, tweets,
+			numTweets, batchSize) {
+			fmt.Printf("  tweet: %q\n", tweet)
+		}
+		fmt.Println()
+	}
+}
+
+func selectUsers(db *tweeters.DB, n, min, max int) ([]*User, error) {
+	var res []*User
+	for _, idx := range rand.Perm(db.NumUsers()) {
+		if len(res) == n {
+			break
+		}
+		records, err := db.Read(idx)
+		if err != nil {
+			return nil, err
+		}
+		if len(records) < min {
+			continue
+		}
+		user := &User{Name: string(records[0].User)}
+		for _, i := range rand.Perm(len(records))[:essentials.MinInt(len(records), max)] {
+			user.Tweets = append(user.Tweets, records[i].Body)
+		}
+		res = append(res, user)
+	}
+	return res, nil
+}
+
+func embedUsers(model *tweeters.Model, users []*User, batchSize int) {
+	for i := 0; i < len(users); {
+		var tweets [][]byte
+		var sizes []int
+		start := i
+		for i < len(users) && (len(tweets) == 0 ||
+			len(tweets)+len(users[i].Tweets) <= batchSize) {
+			tweets = append(tweets, users[i].Tweets...)
+			sizes = append(sizes, len(users[i].Tweets))
+			i++
+		}
+		vecs := splitFloats(model.Averages(tweets, sizes).Output(), len(sizes))
+		for j, vec := range vecs {
+			users[start+j].Vector = vec
+		}
+	}
+}
+
+func characteristicTweets(model *tweeters.Model, kmeans *KMeans, center []float64,
+	tweets [][]byte, n, batchSize int) [][]byte {
+	scores := make([]float64, len(tweets))
+	for i := 0; i < len(tweets); i += batchSize {
+		batch := tweets[i:essentials.MinInt(len(tweets), i+batchSize)]
+		vecs := splitFloats(model.Encode(batch).Output(), len(batch))
+		for j, vec := range vecs {
+			if kmeans.Spherical {
+				normalize(vec)
+			}
+			scores[i+j] = kmeans.Similarity(center, vec)
+		}
+	}
+	indices := make([]int, len(tweets))
+	for i := range indices {
+		indices[i] = i
+	}
+	sort.Slice(indices, func(i, j int) bool {
+		return scores[indices[i]] > scores[indices[j]]
+	})
+	var res [][]byte
+	for _, i := range indices[:essentials.MinInt(n, len(indices))] {
+		res = append(res, tweets[i])
+	}
+	return res
+}
+
+func writeAssignments(path string, users []*User, assignments []int) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	w := csv.NewWriter(f)
+	for i, user := range users {
+		if err := w.Write([]string{user.Name, strconv.Itoa(assignments[i])}); err != nil {
+			return err
+		}
+	}
+	w.Flush()
+	return w.Error()
+}
+
+func splitFloats(v anyvec.Vector, n int) [][]float64 {
+	data := tweeters.VectorFloats(v)
+	size := len(data) / n
+	res := make([][]float64, n)
+	for i := range res {
+		res[i] = make([]float64, size)
+		copy(res[i], data[i*size:(i+1)*size])
+	}
+	return res
+}
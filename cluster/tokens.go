@@ -0,0 +1,73 @@
+package main
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Tokenize splits a tweet into lowercase words, stripping
+// leading and trailing punctuation.
+func Tokenize(tweet []byte) []string {
+	var res []string
+	for _, field := range strings.Fields(strings.ToLower(string(tweet))) {
+		word := strings.TrimFunc(field, func(r rune) bool {
+			return unicode.IsPunct(r) && r != '#' && r != '@'
+		})
+		if word != "" {
+			res = append(res, word)
+		}
+	}
+	return res
+}
+
+// TokenCounts counts word frequencies.
+type TokenCounts struct {
+	Counts map[string]int
+	Total  int
+}
+
+// NewTokenCounts creates an empty TokenCounts.
+func NewTokenCounts() *TokenCounts {
+	return &TokenCounts{Counts: map[string]int{}}
+}
+
+// Add counts the words in a tweet.
+func (t *TokenCounts) Add(tweet []byte) {
+	for _, word := range Tokenize(tweet) {
+		t.Counts[word]++
+		t.Total++
+	}
+}
+
+// Distinctive finds the n words that are most
+// over-represented in t compared to the background
+// counts.
+//
+// Words that occur fewer than minCount times in t are
+// ignored.
+// Frequencies are smoothed by adding one to every count.
+func (t *TokenCounts) Distinctive(background *TokenCounts, n, minCount int) []string {
+	vocab := float64(len(background.Counts) + 1)
+	var words []string
+	scores := map[string]float64{}
+	for word, count := range t.Counts {
+		if count < minCount {
+			continue
+		}
+		freq := (float64(count) + 1) / (float64(t.Total) + vocab)
+		bgFreq := (float64(background.Counts[word]) + 1) / (float64(background.Total) + vocab)
+		scores[word] = freq / bgFreq
+		words = append(words, word)
+	}
+	sort.Slice(words, func(i, j int) bool {
+		if scores[words[i]] == scores[words[j]] {
+			return words[i] < words[j]
+		}
+		return scores[words[i]] > scores[words[j]]
+	})
+	if len(words) > n {
+		words = words[:n]
+	}
+	return words
+}
@@ -0,0 +1,75 @@
+package tweeters
+
+import (
+	"github.com/unixpickle/anydiff"
+	"github.com/unixpickle/anyvec"
+)
+
+// A Context is a set of context tweets which has been
+// encoded once and can be compared against any number of
+// candidate tweets.
+//
+// This is much faster than calling Model.Averages for
+// every candidate, since the context tweets only go
+// through the encoder a single time.
+type Context struct {
+	Model *Model
+
+	// Vector is the averaged latent vector of the
+	// context tweets.
+	Vector anyvec.Vector
+
+	// Latent contains the packed latent vectors of the
+	// individual context tweets.
+	// It is used for models with an Aggregator.
+	Latent anyvec.Vector
+}
+
+// NewContext encodes and averages the context tweets.
+func NewContext(m *Model, tweets [][]byte) *Context {
+	latent := m.Encode(tweets)
+	return &Context{
+		Model:  m,
+		Vector: averageLatent(latent, len(tweets), []int{len(tweets)}).Output(),
+		Latent: latent.Output(),
+	}
+}
+
+// Score computes the classifier's same-author logit for
+// each of the candidate tweets.
+//
+// The candidates are encoded and classified batchSize
+// tweets at a time.
+func (c *Context) Score(candidates [][]byte, batchSize int) []float64 {
+	if batchSize <= 0 {
+		panic("batch size must be positive")
+	}
+	var res []float64
+	for i := 0; i < len(candidates); i += batchSize {
+		batch := candidates[i:]
+		if len(batch) > batchSize {
+			batch = batch[:batchSize]
+		}
+		res = append(res, c.scoreBatch(batch)...)
+	}
+	return res
+}
+
+func (c *Context) scoreBatch(candidates [][]byte) []float64 {
+	latent := c.Model.Encode(candidates)
+
+	// Without an aggregator, the average of the context is
+	// all that matters, and it is already known.
+	context, size := c.Latent, c.Latent.Len()/c.Vector.Len()
+	if c.Model.Aggregator == nil {
+		context, size = c.Vector, 1
+	}
+
+	contexts := make([]anyvec.Vector, len(candidates))
+	sizes := make([]int, len(candidates))
+	for i := range candidates {
+		contexts[i], sizes[i] = context, size
+	}
+	in := anydiff.NewConst(context.Creator().Concat(contexts...))
+	return VectorFloats(c.Model.compare(in, sizes, latent).Output())
+}
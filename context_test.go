@@ -0,0 +1,69 @@
+package tweeters
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/unixpickle/anyvec/anyvec64"
+)
+
+func TestContextScore(t *testing.T) {
+	model := NewModel(anyvec64.CurrentCreator(), nil, 0, 16, 1)
+	context := randomTweets(5)
+	candidates := randomTweets(7)
+
+	actual := NewContext(model, context).Score(candidates, 3)
+	expected := naiveScores(model, context, candidates)
+	if len(actual) != len(expected) {
+		t.Fatalf("expected %d scores but got %d", len(expected), len(actual))
+	}
+	for i, x := range expected {
+		if math.Abs(actual[i]-x) > 1e-5 {
+			t.Errorf("candidate %d: expected %f but got %f", i, x, actual[i])
+		}
+	}
+}
+
+func BenchmarkContextScore(b *testing.B) {
+	model := NewModel(anyvec64.CurrentCreator(), nil, 0, 64, 1)
+	context := randomTweets(16)
+	candidates := randomTweets(64)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		NewContext(model, context).Score(candidates, 32)
+	}
+}
+
+func BenchmarkNaiveScore(b *testing.B) {
+	model := NewModel(anyvec64.CurrentCreator(), nil, 0, 64, 1)
+	context := randomTweets(16)
+	candidates := randomTweets(64)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		naiveScores(model, context, candidates)
+	}
+}
+
+// naiveScores re-encodes the context for every candidate,
+// as one would when calling Model.Averages directly.
+func naiveScores(m *Model, context, candidates [][]byte) []float64 {
+	var res []float64
+	for _, candidate := range candidates {
+		tweets := append(append([][]byte{}, context...), candidate)
+		latent := m.Averages(tweets, []int{len(context), 1})
+		res = append(res, VectorFloats(m.Classifier.Apply(latent, 1).Output())...)
+	}
+	return res
+}
+
+func randomTweets(n int) [][]byte {
+	res := make([][]byte, n)
+	for i := range res {
+		res[i] = make([]byte, 10+rand.Intn(100))
+		for j := range res[i] {
+			res[i][j] = byte(' ' + rand.Intn(95))
+		}
+	}
+	return res
+}
@@ -0,0 +1,234 @@
+package tweeters
+
+import (
+	"errors"
+
+	"github.com/unixpickle/anydiff"
+	"github.com/unixpickle/anydiff/anyseq"
+	"github.com/unixpickle/anynet"
+	"github.com/unixpickle/anyvec"
+	"github.com/unixpickle/essentials"
+	"github.com/unixpickle/serializer"
+)
+
+func init() {
+	serializer.RegisterTypedDeserializer((&ConvEncoder{}).SerializerType(),
+		DeserializeConvEncoder)
+	serializer.RegisterTypedDeserializer((&ConvLayer{}).SerializerType(),
+		DeserializeConvLayer)
+}
+
+// ConvEncoder is a stack of 1-D convolutions over the
+// tokens of each tweet.
+//
+// Unlike a recurrent encoder, every timestep of every
+// layer is computed at once, making it much faster on
+// CPUs.
+type ConvEncoder struct {
+	Layers []*ConvLayer
+}
+
+// NewConvEncoder creates a stack of gated convolutions.
+//
+// The dilation doubles with each layer, starting at 1,
+// so that the receptive field grows exponentially with
+// the number of layers.
+func NewConvEncoder(c anyvec.Creator, inSize, outSize, width, numLayers int) *ConvEncoder {
+	res := &ConvEncoder{}
+	for i := 0; i < numLayers; i++ {
+		res.Layers = append(res.Layers, NewConvLayer(c, inSize, outSize, width, 1<<uint(i)))
+		inSize = outSize
+	}
+	return res
+}
+
+// DeserializeConvEncoder deserializes a ConvEncoder.
+func DeserializeConvEncoder(d []byte) (*ConvEncoder, error) {
+	objs, err := serializer.DeserializeSlice(d)
+	if err != nil {
+		return nil, essentials.AddCtx("deserialize ConvEncoder", err)
+	}
+	res := &ConvEncoder{}
+	for _, obj := range objs {
+		layer, ok := obj.(*ConvLayer)
+		if !ok {
+			return nil, essentials.AddCtx("deserialize ConvEncoder",
+				errors.New("invalid layer type"))
+		}
+		res.Layers = append(res.Layers, layer)
+	}
+	return res, nil
+}
+
+// Apply applies the convolutions to an input sequence,
+// producing an output sequence of the same shape.
+func (c *ConvEncoder) Apply(in anyseq.Seq) anyseq.Seq {
+	flat := newFlatSeq(in)
+	var res anydiff.Res = flat
+	for _, layer := range c.Layers {
+		res = layer.apply(flat, res)
+	}
+	return newUnflatSeq(flat, res)
+}
+
+// Parameters returns the parameters of every layer.
+func (c *ConvEncoder) Parameters() []*anydiff.Var {
+	var res []*anydiff.Var
+	for _, layer := range c.Layers {
+		res = append(res, layer.Parameters()...)
+	}
+	return res
+}
+
+// SerializerType returns the unique ID used to serialize
+// a ConvEncoder with the serializer package.
+func (c *ConvEncoder) SerializerType() string {
+	return "github.com/unixpickle/tweeters.ConvEncoder"
+}
+
+// Serialize serializes the ConvEncoder.
+func (c *ConvEncoder) Serialize() ([]byte, error) {
+	var objs []serializer.Serializer
+	for _, layer := range c.Layers {
+		objs = append(objs, layer)
+	}
+	return serializer.SerializeSlice(objs)
+}
+
+// ConvLayer is a gated, dilated 1-D convolution.
+//
+// Each output is tanh(v)*sigmoid(g), where v and g are
+// computed from a window of Width inputs, spaced Dilation
+// timesteps apart and centered on the output's timestep.
+// Windows are zero-padded at the edges of each tweet.
+//
+// If the input and output sizes match, the layer's input
+// is added to its output.
+type ConvLayer struct {
+	Width    int
+	Dilation int
+
+	// Values and Gates map a concatenated window of inputs
+	// to the output values and gates, respectively.
+	Values *anynet.FC
+	Gates  *anynet.FC
+}
+
+// NewConvLayer creates a randomly-initialized ConvLayer.
+func NewConvLayer(c anyvec.Creator, inSize, outSize, width, dilation int) *ConvLayer {
+	return &ConvLayer{
+		Width:    width,
+		Dilation: dilation,
+		Values:   anynet.NewFC(c, inSize*width, outSize),
+		Gates:    anynet.NewFC(c, inSize*width, outSize),
+	}
+}
+
+// DeserializeConvLayer deserializes a ConvLayer.
+func DeserializeConvLayer(d []byte) (*ConvLayer, error) {
+	var width, dilation serializer.Int
+	var res ConvLayer
+	err := serializer.DeserializeAny(d, &width, &dilation, &res.Values, &res.Gates)
+	if err != nil {
+		return nil, essentials.AddCtx("deserialize ConvLayer", err)
+	}
+	res.Width = int(width)
+	res.Dilation = int(dilation)
+	return &res, nil
+}
+
+// Parameters returns the layer's parameters.
+func (c *ConvLayer) Parameters() []*anydiff.Var {
+	return append(c.Values.Parameters(), c.Gates.Parameters()...)
+}
+
+// SerializerType returns the unique ID used to serialize
+// a ConvLayer with the serializer package.
+func (c *ConvLayer) SerializerType() string {
+	return "github.com/unixpickle/tweeters.ConvLayer"
+}
+
+// Serialize serializes the ConvLayer.
+func (c *ConvLayer) Serialize() ([]byte, error) {
+	return serializer.SerializeAny(serializer.Int(c.Width), serializer.Int(c.Dilation),
+		c.Values, c.Gates)
+}
+
+// apply applies the layer to a matrix of inputs laid out
+// like the rows of layout.
+func (c *ConvLayer) apply(layout *flatSeq, in anydiff.Res) anydiff.Res {
+	numRows := len(layout.rowTweets)
+	inSize := in.Output().Len() / numRows
+	return anydiff.Pool(in, func(in anydiff.Res) anydiff.Res {
+		// The final row of padded is all zeros.
+		cr := in.Output().Creator()
+		padded := anydiff.Concat(in, anydiff.NewConst(cr.MakeVector(inSize)))
+		table := make([]int, 0, numRows*c.Width*inSize)
+		for k, i := range layout.rowTweets {
+			t := layout.rowTimes[k]
+			for j := 0; j < c.Width; j++ {
+				src := numRows
+				srcTime := t + c.Dilation*(j-(c.Width-1)/2)
+				if srcTime >= 0 && srcTime < len(layout.tweetRows[i]) {
+					src = layout.tweetRows[i][srcTime]
+				}
+				for x := 0; x < inSize; x++ {
+					table = append(table, src*inSize+x)
+				}
+			}
+		}
+		windows := anydiff.Map(cr.MakeMapper((numRows+1)*inSize, table), padded)
+		out := anydiff.Pool(windows, func(windows anydiff.Res) anydiff.Res {
+			return anydiff.Mul(
+				anydiff.Tanh(c.Values.Apply(windows, numRows)),
+				anydiff.Sigmoid(c.Gates.Apply(windows, numRows)),
+			)
+		})
+		if c.Values.OutCount == inSize {
+			out = anydiff.Add(out, in)
+		}
+		return out
+	})
+}
+
+// unflatSeq splits a matrix laid out like a flatSeq back
+// into a sequence.
+type unflatSeq struct {
+	res anydiff.Res
+	out []*anyseq.Batch
+}
+
+func newUnflatSeq(layout *flatSeq, res anydiff.Res) *unflatSeq {
+	cols := res.Output().Len() / len(layout.rowTweets)
+	var out []*anyseq.Batch
+	var offset int
+	for i, batch := range layout.seq.Output() {
+		size := layout.counts[i] * cols
+		out = append(out, &anyseq.Batch{
+			Present: batch.Present,
+			Packed:  res.Output().Slice(offset, offset+size),
+		})
+		offset += size
+	}
+	return &unflatSeq{res: res, out: out}
+}
+
+func (u *unflatSeq) Creator() anyvec.Creator {
+	return u.res.Output().Creator()
+}
+
+func (u *unflatSeq) Output() []*anyseq.Batch {
+	return u.out
+}
+
+func (u *unflatSeq) Vars() anydiff.VarSet {
+	return u.res.Vars()
+}
+
+func (u *unflatSeq) Propagate(upstream []*anyseq.Batch, grad anydiff.Grad) {
+	var vecs []anyvec.Vector
+	for _, batch := range upstream {
+		vecs = append(vecs, batch.Packed)
+	}
+	u.res.Propagate(u.Creator().Concat(vecs...), grad)
+}
@@ -0,0 +1,72 @@
+package tweeters
+
+import (
+	"math"
+	"testing"
+
+	"github.com/unixpickle/anydiff"
+	"github.com/unixpickle/anydiff/anyseq"
+	"github.com/unixpickle/anyvec/anyvec64"
+)
+
+func TestConvLayer(t *testing.T) {
+	c := anyvec64.CurrentCreator()
+	layer := NewConvLayer(c, 2, 3, 3, 2)
+	seq := anyseq.ConstSeq(c, []*anyseq.Batch{
+		{Present: []bool{true, true}, Packed: c.MakeVectorData([]float64{1, 2, 3, 4})},
+		{Present: []bool{true, false}, Packed: c.MakeVectorData([]float64{5, 6})},
+		{Present: []bool{true, false}, Packed: c.MakeVectorData([]float64{7, 8})},
+	})
+	actual := (&ConvEncoder{Layers: []*ConvLayer{layer}}).Apply(seq).Output()
+
+	// With a dilation of 2, the first timestep of the first
+	// tweet sees [0, x0, x2], the second sees [0, x1, 0],
+	// and so on.
+	windows := [][]float64{
+		{0, 0, 1, 2, 7, 8},
+		{0, 0, 3, 4, 0, 0},
+		{0, 0, 5, 6, 0, 0},
+		{1, 2, 7, 8, 0, 0},
+	}
+	rows := [][]int{{0, 1}, {2}, {3}}
+	for step, batch := range actual {
+		data := VectorFloats(batch.Packed)
+		for r, row := range rows[step] {
+			expected := naiveConv(layer, windows[row])
+			for j, x := range expected {
+				if math.Abs(data[r*3+j]-x) > 1e-8 {
+					t.Errorf("timestep %d row %d: expected %v but got %v", step, r,
+						expected, data[r*3:(r+1)*3])
+					break
+				}
+			}
+		}
+	}
+}
+
+func TestConvModel(t *testing.T) {
+	c := anyvec64.CurrentCreator()
+	arch := &Architecture{Cell: "lstm", Layers: 0, ConvLayers: 3, ConvWidth: 3,
+		Hidden: 8, EmbedDim: 4, Pooling: "max", Dropout: 1}
+	model, err := arch.NewModel(c, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tweets := randomTweets(3)
+	if size := model.Encode(tweets).Output().Len(); size != 3*8 {
+		t.Fatalf("unexpected latent size %d", size)
+	}
+	checkRoundTrip(t, model, tweets)
+}
+
+func naiveConv(layer *ConvLayer, window []float64) []float64 {
+	c := anyvec64.CurrentCreator()
+	in := anydiff.NewConst(c.MakeVectorData(window))
+	values := VectorFloats(layer.Values.Apply(in, 1).Output())
+	gates := VectorFloats(layer.Gates.Apply(in, 1).Output())
+	res := make([]float64, len(values))
+	for i, v := range values {
+		res[i] = math.Tanh(v) / (1 + math.Exp(-gates[i]))
+	}
+	return res
+}
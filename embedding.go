@@ -0,0 +1,79 @@
+package tweeters
+
+import (
+	"math"
+
+	"github.com/unixpickle/anydiff"
+	"github.com/unixpickle/anydiff/anyseq"
+	"github.com/unixpickle/anynet"
+	"github.com/unixpickle/anyvec"
+)
+
+// NewEmbedding creates a randomly-initialized embedding
+// table for the model's Embedding field.
+//
+// The table is stored as a fully-connected layer from a
+// one-hot token vector to an embedding, but it is only
+// ever applied by looking up columns of its weights.
+// The entries of every embedding are roughly distributed
+// according to a unit normal.
+func NewEmbedding(c anyvec.Creator, vocabSize, dim int) *anynet.FC {
+	res := anynet.NewFC(c, vocabSize, dim)
+	res.Weights.Vector.Scale(c.MakeNumeric(math.Sqrt(float64(vocabSize))))
+	return res
+}
+
+// embedTokens looks up the embeddings for a list of token
+// IDs, producing a packed list of embeddings.
+//
+// This is equivalent to applying the layer to one-hot
+// vectors, but it never materializes the one-hot vectors.
+func embedTokens(layer *anynet.FC, ids []int) anydiff.Res {
+	c := layer.Weights.Vector.Creator()
+	table := make([]int, 0, len(ids)*layer.OutCount)
+	for _, id := range ids {
+		for j := 0; j < layer.OutCount; j++ {
+			table = append(table, j*layer.InCount+id)
+		}
+	}
+	mapper := c.MakeMapper(layer.Weights.Vector.Len(), table)
+	return anydiff.AddRepeated(anydiff.Map(mapper, layer.Weights), layer.Biases)
+}
+
+// resSeq is a sequence whose timesteps are arbitrary
+// differentiable results.
+type resSeq struct {
+	creator anyvec.Creator
+	res     []anydiff.Res
+	out     []*anyseq.Batch
+}
+
+func newResSeq(c anyvec.Creator, present [][]bool, res []anydiff.Res) *resSeq {
+	out := make([]*anyseq.Batch, len(res))
+	for i, r := range res {
+		out[i] = &anyseq.Batch{Present: present[i], Packed: r.Output()}
+	}
+	return &resSeq{creator: c, res: res, out: out}
+}
+
+func (r *resSeq) Creator() anyvec.Creator {
+	return r.creator
+}
+
+func (r *resSeq) Output() []*anyseq.Batch {
+	return r.out
+}
+
+func (r *resSeq) Vars() anydiff.VarSet {
+	var sets []anydiff.VarSet
+	for _, res := range r.res {
+		sets = append(sets, res.Vars())
+	}
+	return anydiff.MergeVarSets(sets...)
+}
+
+func (r *resSeq) Propagate(upstream []*anyseq.Batch, grad anydiff.Grad) {
+	for i, batch := range upstream {
+		r.res[i].Propagate(batch.Packed, grad)
+	}
+}
@@ -0,0 +1,65 @@
+package tweeters
+
+import (
+	"math"
+	"testing"
+
+	"github.com/unixpickle/anydiff"
+	"github.com/unixpickle/anyvec/anyvec64"
+)
+
+func TestEmbedTokens(t *testing.T) {
+	c := anyvec64.CurrentCreator()
+	layer := NewEmbedding(c, 7, 3)
+	ids := []int{4, 0, 4, 6}
+
+	oneHot := make([]float64, len(ids)*7)
+	for i, id := range ids {
+		oneHot[i*7+id] = 1
+	}
+	expected := layer.Apply(anydiff.NewConst(c.MakeVectorData(oneHot)), len(ids))
+	actual := embedTokens(layer, ids)
+
+	expData := VectorFloats(expected.Output())
+	actData := VectorFloats(actual.Output())
+	if len(expData) != len(actData) {
+		t.Fatalf("expected %d outputs but got %d", len(expData), len(actData))
+	}
+	for i, x := range expData {
+		if math.Abs(x-actData[i]) > 1e-8 {
+			t.Errorf("output %d: expected %f but got %f", i, x, actData[i])
+		}
+	}
+}
+
+func TestEmbeddingModel(t *testing.T) {
+	model := NewModel(anyvec64.CurrentCreator(), nil, 8, 16, 1)
+	decoded := checkRoundTrip(t, model, randomTweets(4))
+	if decoded.Embedding == nil {
+		t.Fatal("embedding was not deserialized")
+	}
+	if len(model.Parameters()) != len(decoded.Parameters()) {
+		t.Error("parameter count mismatch")
+	}
+}
+
+func TestRepurposedModel(t *testing.T) {
+	// The repurpose command produces models with nothing
+	// but an encoder and a classifier.
+	c := anyvec64.CurrentCreator()
+	arch := DefaultArchitecture()
+	arch.Layers = 2
+	arch.Hidden = 16
+	model := &Model{
+		Encoder:    arch.newEncoder(c, 0x100),
+		Classifier: NewClassifier(c, 32, []int{8}),
+	}
+
+	decoded := checkRoundTrip(t, model, randomTweets(4))
+	if decoded.Embedding != nil || decoded.Tokenizer != nil {
+		t.Fatal("unexpected embedding or tokenizer")
+	}
+	if len(decoded.Parameters()) != len(model.Parameters()) {
+		t.Error("parameter count mismatch")
+	}
+}
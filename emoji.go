@@ -0,0 +1,32 @@
+package tweeters
+
+// IsEmoji checks if a rune is an emoji, or a character
+// used to modify or join emoji (such as a variation
+// selector or a zero-width joiner).
+//
+// Other symbols, such as "^" or "©", are not considered
+// emoji.
+func IsEmoji(r rune) bool {
+	switch {
+	case r >= 0x1F000 && r <= 0x1FAFF:
+		// Pictographs, emoticons, flags, and skin tones.
+		return true
+	case r >= 0x2600 && r <= 0x27BF:
+		// Miscellaneous symbols and dingbats.
+		return true
+	case r >= 0x2B00 && r <= 0x2BFF:
+		// Arrows and shapes like ⭐ and ⬛.
+		return true
+	case r >= 0xFE00 && r <= 0xFE0F:
+		// Variation selectors.
+		return true
+	case r >= 0xE0020 && r <= 0xE007F:
+		// Tags used in subdivision flags.
+		return true
+	case r == 0x200D || r == 0x20E3:
+		// Zero-width joiner and combining keycap.
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,16 @@
+package tweeters
+
+import "testing"
+
+func TestIsEmoji(t *testing.T) {
+	for _, r := range "😀❤️👍🏽⭐☕" {
+		if !IsEmoji(r) {
+			t.Errorf("expected %q (%U) to be an emoji", r, r)
+		}
+	}
+	for _, r := range "a^`~©°é!#" {
+		if IsEmoji(r) {
+			t.Errorf("expected %q (%U) not to be an emoji", r, r)
+		}
+	}
+}
@@ -0,0 +1,234 @@
+package tweeters
+
+import (
+	"github.com/unixpickle/anydiff"
+	"github.com/unixpickle/anydiff/anyseq"
+	"github.com/unixpickle/anyvec"
+)
+
+// An Explanation attributes a same-author decision to the
+// individual bytes of the tweets involved.
+type Explanation struct {
+	// Tweets contains the context tweets followed by the
+	// candidate tweet.
+	Tweets [][]byte
+
+	// Logit is the classifier's same-author logit.
+	Logit float64
+
+	// Saliency stores a gradient-times-input score for
+	// each byte of each tweet.
+	// Positive scores push the classifier towards a
+	// same-author prediction.
+	// When a token spans several bytes, its score is split
+	// evenly between them.
+	Saliency [][]float64
+
+	// Occlusion stores, for each byte of each tweet, how
+	// much the logit drops when that byte is deleted.
+	// For single-byte tweets, the byte is replaced with a
+	// space instead.
+	Occlusion [][]float64
+}
+
+// Explain computes an Explanation for the classifier's
+// decision on a context and a candidate tweet.
+//
+// The occlusion scores require encoding one modified copy
+// of every tweet for each of its bytes.
+// These copies are encoded batchSize tweets at a time.
+func Explain(m *Model, context [][]byte, candidate []byte, batchSize int) *Explanation {
+	if len(context) == 0 {
+		panic("at least one context tweet is required")
+	}
+	tweets := append(append([][]byte{}, context...), candidate)
+	res := &Explanation{Tweets: tweets}
+	res.Logit, res.Saliency = saliency(m, tweets)
+	res.Occlusion = occlusion(m, tweets, res.Logit, batchSize)
+	return res
+}
+
+func saliency(m *Model, tweets [][]byte) (float64, [][]float64) {
+	tokens := m.tokenize(tweets)
+	forward := newVarSeq(m.creator(), m.inputBatches(tokens))
+	var backward *varSeq
+	vars := forward.vars
+	if m.Backward != nil {
+		backward = newVarSeq(m.creator(), m.inputBatches(reverseTokens(tokens)))
+		vars = append(append([]*anydiff.Var{}, vars...), backward.vars...)
+	}
+	var backwardSeq anyseq.Seq
+	if backward != nil {
+		backwardSeq = backward
+	}
+	logit := m.classifyLatent(m.encodeSeq(forward, backwardSeq, len(tweets)), len(tweets),
+		[]int{len(tweets) - 1, 1})
+
+	grad := anydiff.NewGrad(vars...)
+	c := logit.Output().Creator()
+	one := c.MakeVector(1)
+	one.AddScalar(c.MakeNumeric(1))
+	logit.Propagate(one, grad)
+
+	res := make([][]float64, len(tweets))
+	for i, tweet := range tweets {
+		res[i] = make([]float64, len(tweet))
+	}
+	addSaliency(res, tokens, forward, grad, false)
+	if backward != nil {
+		addSaliency(res, tokens, backward, grad, true)
+	}
+	return VectorFloats(logit.Output())[0], res
+}
+
+// addSaliency adds the gradient-times-input scores for
+// one of the encoder's input sequences to res.
+//
+// If reversed is true, the inputs are assumed to be the
+// tokens in reverse order.
+func addSaliency(res [][]float64, tokens [][]Token, in *varSeq, grad anydiff.Grad,
+	reversed bool) {
+	for t, batch := range in.batches {
+		gradData := VectorFloats(grad[in.vars[t]])
+		inData := VectorFloats(batch.Packed)
+		var row int
+		for _, pres := range batch.Present {
+			if pres {
+				row++
+			}
+		}
+		inSize := len(gradData) / row
+		row = 0
+		for i, pres := range batch.Present {
+			if pres {
+				var score float64
+				for k := row * inSize; k < (row+1)*inSize; k++ {
+					score += gradData[k] * inData[k]
+				}
+				tok := tokens[i][t]
+				if reversed {
+					tok = tokens[i][len(tokens[i])-(t+1)]
+				}
+				score /= float64(tok.End - tok.Start)
+				for j := tok.Start; j < tok.End; j++ {
+					res[i][j] += score
+				}
+				row++
+			}
+		}
+	}
+}
+
+func occlusion(m *Model, tweets [][]byte, logit float64, batchSize int) [][]float64 {
+	latent := m.Encode(tweets).Output()
+	latentSize := latent.Len() / len(tweets)
+	numContext := len(tweets) - 1
+	context := latent.Slice(0, numContext*latentSize)
+	candidate := latent.Slice(numContext*latentSize, latent.Len())
+
+	var variants [][]byte
+	for _, tweet := range tweets {
+		for j := range tweet {
+			variant := append(append([]byte{}, tweet[:j]...), tweet[j+1:]...)
+			if len(variant) == 0 {
+				variant = []byte(" ")
+			}
+			variants = append(variants, variant)
+		}
+	}
+
+	var contexts, candidates []anyvec.Vector
+	var variantIdx int
+	for i := 0; i < len(variants); i += batchSize {
+		batch := variants[i:]
+		if len(batch) > batchSize {
+			batch = batch[:batchSize]
+		}
+		encoded := m.Encode(batch).Output()
+		for j := range batch {
+			vec := encoded.Slice(j*latentSize, (j+1)*latentSize)
+			tweetIdx := variantTweet(tweets, variantIdx)
+			if tweetIdx == numContext {
+				contexts = append(contexts, context)
+				candidates = append(candidates, vec)
+			} else {
+				// Swap the occluded copy into the context.
+				contexts = append(contexts,
+					latent.Slice(0, tweetIdx*latentSize),
+					vec,
+					latent.Slice((tweetIdx+1)*latentSize, numContext*latentSize))
+				candidates = append(candidates, candidate)
+			}
+			variantIdx++
+		}
+	}
+
+	res := make([][]float64, len(tweets))
+	if len(variants) == 0 {
+		return res
+	}
+	c := m.creator()
+	sizes := make([]int, len(variants))
+	for i := range sizes {
+		sizes[i] = numContext
+	}
+	occluded := VectorFloats(m.compare(anydiff.NewConst(c.Concat(contexts...)), sizes,
+		anydiff.NewConst(c.Concat(candidates...))).Output())
+	for i, tweet := range tweets {
+		res[i] = make([]float64, len(tweet))
+		for j := range tweet {
+			res[i][j] = logit - occluded[0]
+			occluded = occluded[1:]
+		}
+	}
+	return res
+}
+
+// variantTweet finds the index of the tweet which was
+// occluded to produce the given variant.
+func variantTweet(tweets [][]byte, variantIdx int) int {
+	for i, tweet := range tweets {
+		if variantIdx < len(tweet) {
+			return i
+		}
+		variantIdx -= len(tweet)
+	}
+	panic("variant index out of range")
+}
+
+// varSeq is an input sequence whose timesteps are
+// variables, making it possible to compute gradients with
+// respect to the inputs.
+type varSeq struct {
+	creator anyvec.Creator
+	batches []*anyseq.Batch
+	vars    []*anydiff.Var
+}
+
+func newVarSeq(c anyvec.Creator, batches []*anyseq.Batch) *varSeq {
+	res := &varSeq{creator: c, batches: batches}
+	for _, batch := range batches {
+		res.vars = append(res.vars, anydiff.NewVar(batch.Packed))
+	}
+	return res
+}
+
+func (v *varSeq) Creator() anyvec.Creator {
+	return v.creator
+}
+
+func (v *varSeq) Output() []*anyseq.Batch {
+	return v.batches
+}
+
+func (v *varSeq) Vars() anydiff.VarSet {
+	return anydiff.NewVarSet(v.vars...)
+}
+
+func (v *varSeq) Propagate(upstream []*anyseq.Batch, grad anydiff.Grad) {
+	for i, batch := range upstream {
+		if g, ok := grad[v.vars[i]]; ok {
+			g.Add(batch.Packed)
+		}
+	}
+}
@@ -0,0 +1,165 @@
+// Command explain shows which characters a model relies
+// on when deciding if a tweet was written by the same
+// author as a set of context tweets.
+//
+// Characters are highlighted using gradient-based
+// saliency or occlusion scores.
+// The context and candidate can be given explicitly, or
+// sampled from a tweet DB.
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"time"
+
+	"github.com/unixpickle/essentials"
+	"github.com/unixpickle/serializer"
+	"github.com/unixpickle/tweeters"
+)
+
+func main() {
+	rand.Seed(time.Now().UnixNano())
+
+	var modelPath string
+	var dbPath string
+	var contextPath string
+	var candidate string
+	var method string
+	var htmlPath string
+	var batchSize int
+	var minTweets, maxTweets int
+	flag.StringVar(&modelPath, "model", "../train/model_out", "path to trained model")
+	flag.StringVar(&dbPath, "data", "", "path to tweet DB for random samples")
+	flag.StringVar(&contextPath, "context", "", "file with one context tweet per line")
+	flag.StringVar(&candidate, "candidate", "", "candidate tweet (with -context)")
+	flag.StringVar(&method, "method", "saliency", "scores to show (saliency or occlusion)")
+	flag.StringVar(&htmlPath, "html", "", "write HTML to this file instead of the terminal")
+	flag.IntVar(&batchSize, "batch", 128, "batch size for occlusion")
+	flag.IntVar(&minTweets, "min", 3, "minimum tweets per sampled user")
+	flag.IntVar(&maxTweets, "max", 16, "maximum tweets per sampled user")
+	flag.Parse()
+
+	if (dbPath == "") == (contextPath == "") {
+		essentials.Die("Exactly one of -data or -context is required. See -help.")
+	}
+	if method != "saliency" && method != "occlusion" {
+		essentials.Die("unknown method:", method)
+	}
+
+	var model *tweeters.Model
+	if err := serializer.LoadAny(modelPath, &model); err != nil {
+		essentials.Die(err)
+	}
+	model.SetDropout(false)
+
+	var context [][]byte
+	var candidateBytes []byte
+	var err error
+	if contextPath != "" {
+		context, err = readLines(contextPath)
+		candidateBytes = []byte(candidate)
+	} else {
+		context, candidateBytes, err = sampleTweets(dbPath, minTweets, maxTweets)
+	}
+	if err != nil {
+		essentials.Die(err)
+	}
+	if len(context) == 0 || len(candidateBytes) == 0 {
+		essentials.Die("need at least one context tweet and a candidate")
+	}
+
+	exp := tweeters.Explain(model, context, candidateBytes, batchSize)
+	scores := exp.Saliency
+	if method == "occlusion" {
+		scores = exp.Occlusion
+	}
+	var spans [][]Span
+	for i, tweet := range exp.Tweets {
+		spans = append(spans, Spans(tweet, scores[i]))
+	}
+	maxScore := MaxAbsScore(spans...)
+
+	if htmlPath != "" {
+		f, err := os.Create(htmlPath)
+		if err != nil {
+			essentials.Die(err)
+		}
+		defer f.Close()
+		fmt.Fprintln(f, "<!doctype html>\n<meta charset=\"utf-8\">")
+		err = writeDocument(f, spans, exp.Logit, func(w io.Writer, s []Span) error {
+			return WriteHTML(w, s, maxScore)
+		}, "<h2>%s</h2>\n")
+		if err != nil {
+			essentials.Die(err)
+		}
+	} else {
+		err := writeDocument(os.Stdout, spans, exp.Logit, func(w io.Writer, s []Span) error {
+			return WriteTerminal(w, s, maxScore)
+		}, "--- %s ---\n")
+		if err != nil {
+			essentials.Die(err)
+		}
+	}
+}
+
+func writeDocument(w io.Writer, spans [][]Span, logit float64,
+	writeSpans func(w io.Writer, s []Span) error, heading string) error {
+	fmt.Fprintf(w, heading, "Context")
+	for _, s := range spans[:len(spans)-1] {
+		if err := writeSpans(w, s); err != nil {
+			return err
+		}
+	}
+	fmt.Fprintf(w, heading, "Candidate")
+	if err := writeSpans(w, spans[len(spans)-1]); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintf(w, heading, fmt.Sprintf("Same-author logit: %.4f", logit))
+	return err
+}
+
+func readLines(path string) ([][]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var res [][]byte
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if line := scanner.Text(); line != "" {
+			res = append(res, []byte(line))
+		}
+	}
+	return res, scanner.Err()
+}
+
+func sampleTweets(dbPath string, min, max int) (context [][]byte, candidate []byte,
+	err error) {
+	db, err := tweeters.OpenDB(dbPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer db.Close()
+	samples := tweeters.NewSamples(db)
+	tweets, err := samples.RandomUserTweets(min, max)
+	if err != nil {
+		return nil, nil, err
+	}
+	if rand.Intn(2) == 0 {
+		other, err := samples.RandomUserTweets(1, 1)
+		if err != nil {
+			return nil, nil, err
+		}
+		tweets[len(tweets)-1] = other[0]
+		fmt.Println("Candidate is from a different author.")
+	} else {
+		fmt.Println("Candidate is from the same author.")
+	}
+	return tweets[:len(tweets)-1], tweets[len(tweets)-1], nil
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"math"
+	"strings"
+	"unicode/utf8"
+)
+
+// A Span is a piece of text with an importance score.
+type Span struct {
+	Text  string
+	Score float64
+}
+
+// Spans groups the bytes of a tweet into UTF-8 characters
+// and sums the byte scores for each character.
+func Spans(tweet []byte, scores []float64) []Span {
+	var res []Span
+	for i := 0; i < len(tweet); {
+		_, size := utf8.DecodeRune(tweet[i:])
+		var score float64
+		for _, s := range scores[i : i+size] {
+			score += s
+		}
+		res = append(res, Span{Text: string(tweet[i : i+size]), Score: score})
+		i += size
+	}
+	return res
+}
+
+// MaxAbsScore finds the largest score magnitude, which is
+// used to normalize highlight intensities.
+func MaxAbsScore(spans ...[]Span) float64 {
+	var res float64
+	for _, s := range spans {
+		for _, span := range s {
+			res = math.Max(res, math.Abs(span.Score))
+		}
+	}
+	return res
+}
+
+// WriteTerminal renders highlighted spans with ANSI
+// 256-color backgrounds.
+//
+// Green characters support a same-author prediction and
+// red characters oppose it.
+func WriteTerminal(w io.Writer, spans []Span, maxScore float64) error {
+	var buf strings.Builder
+	for _, span := range spans {
+		level := intensity(span.Score, maxScore, 5)
+		if level == 0 {
+			buf.WriteString(span.Text)
+			continue
+		}
+		var color int
+		if span.Score > 0 {
+			color = 16 + 6*6*(5-level) + 6*5 + (5 - level)
+		} else {
+			color = 16 + 6*6*5 + 6*(5-level) + (5 - level)
+		}
+		fmt.Fprintf(&buf, "\x1b[48;5;%d;30m%s\x1b[0m", color, span.Text)
+	}
+	buf.WriteString("\n")
+	_, err := io.WriteString(w, buf.String())
+	return err
+}
+
+// WriteHTML renders highlighted spans as an HTML
+// paragraph.
+func WriteHTML(w io.Writer, spans []Span, maxScore float64) error {
+	var buf strings.Builder
+	buf.WriteString("<p class=\"tweet\">")
+	for _, span := range spans {
+		alpha := float64(intensity(span.Score, maxScore, 100)) / 100
+		color := "0,160,0"
+		if span.Score < 0 {
+			color = "220,0,0"
+		}
+		fmt.Fprintf(&buf, "<span style=\"background: rgba(%s,%.2f)\" title=\"%.4f\">%s</span>",
+			color, alpha, span.Score, html.EscapeString(span.Text))
+	}
+	buf.WriteString("</p>\n")
+	_, err := io.WriteString(w, buf.String())
+	return err
+}
+
+func intensity(score, maxScore float64, levels int) int {
+	if maxScore == 0 {
+		return 0
+	}
+	return int(math.Round(math.Abs(score) / maxScore * float64(levels)))
+}
@@ -0,0 +1,36 @@
+package tweeters
+
+import (
+	"math"
+	"testing"
+
+	"github.com/unixpickle/anyvec/anyvec64"
+)
+
+func TestExplain(t *testing.T) {
+	model := NewModel(anyvec64.CurrentCreator(), nil, 0, 16, 1)
+	context := randomTweets(3)
+	candidate := []byte("hello, world")
+
+	exp := Explain(model, context, candidate, 7)
+	if len(exp.Tweets) != 4 || len(exp.Saliency) != 4 || len(exp.Occlusion) != 4 {
+		t.Fatal("unexpected number of tweets")
+	}
+	for i, tweet := range exp.Tweets {
+		if len(exp.Saliency[i]) != len(tweet) || len(exp.Occlusion[i]) != len(tweet) {
+			t.Fatalf("tweet %d: unexpected score count", i)
+		}
+	}
+
+	ctx := NewContext(model, context)
+	if logit := ctx.Score([][]byte{candidate}, 1)[0]; math.Abs(logit-exp.Logit) > 1e-5 {
+		t.Errorf("expected logit %f but got %f", logit, exp.Logit)
+	}
+	for j := range candidate {
+		variant := append(append([]byte{}, candidate[:j]...), candidate[j+1:]...)
+		expected := exp.Logit - ctx.Score([][]byte{variant}, 1)[0]
+		if actual := exp.Occlusion[3][j]; math.Abs(actual-expected) > 1e-5 {
+			t.Errorf("byte %d: expected occlusion %f but got %f", j, expected, actual)
+		}
+	}
+}
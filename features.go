@@ -0,0 +1,73 @@
+package tweeters
+
+import (
+	"errors"
+
+	"github.com/unixpickle/anydiff"
+)
+
+// Feature modes determine what the classifier sees when
+// comparing a user vector x to a candidate vector y.
+const (
+	// ConcatFeatures feeds the classifier [x; y].
+	ConcatFeatures = "concat"
+
+	// SymmetricFeatures feeds the classifier
+	// [x*y; |x-y|; cos(x, y)], which does not depend on
+	// the order of x and y.
+	SymmetricFeatures = "symmetric"
+
+	// AllFeatures feeds the classifier
+	// [x; y; x*y; |x-y|; cos(x, y)].
+	AllFeatures = "all"
+)
+
+// FeatureSize returns the size of the classifier's input
+// for a feature mode and latent vector size.
+func FeatureSize(mode string, latentSize int) (int, error) {
+	switch mode {
+	case "", ConcatFeatures:
+		return latentSize * 2, nil
+	case SymmetricFeatures:
+		return latentSize*2 + 1, nil
+	case AllFeatures:
+		return latentSize*4 + 1, nil
+	default:
+		return 0, errors.New("unknown feature mode: " + mode)
+	}
+}
+
+// pairFeatures computes the classifier inputs for n pairs
+// of packed user and candidate vectors.
+func pairFeatures(mode string, users, candidates anydiff.Res, n int) anydiff.Res {
+	if mode == "" || mode == ConcatFeatures {
+		return concatRows(users, candidates, n)
+	}
+	latentSize := users.Output().Len() / n
+	c := users.Output().Creator()
+	return anydiff.Pool(users, func(x anydiff.Res) anydiff.Res {
+		return anydiff.Pool(candidates, func(y anydiff.Res) anydiff.Res {
+			diff := anydiff.Sub(x, y)
+			absDiff := anydiff.Pool(diff, func(diff anydiff.Res) anydiff.Res {
+				return anydiff.Add(anydiff.ClipPos(diff),
+					anydiff.ClipPos(anydiff.Scale(diff, c.MakeNumeric(-1))))
+			})
+			rowSums := func(r anydiff.Res) anydiff.Res {
+				return anydiff.SumCols(&anydiff.Matrix{Data: r, Rows: n, Cols: latentSize})
+			}
+			norm := func(r anydiff.Res) anydiff.Res {
+				return anydiff.Pow(anydiff.AddScalar(rowSums(anydiff.Square(r)),
+					c.MakeNumeric(1e-8)), c.MakeNumeric(0.5))
+			}
+			product := anydiff.Mul(x, y)
+			res := anydiff.Pool(product, func(product anydiff.Res) anydiff.Res {
+				cosine := anydiff.Div(rowSums(product), anydiff.Mul(norm(x), norm(y)))
+				return concatRows(concatRows(product, absDiff, n), cosine, n)
+			})
+			if mode == AllFeatures {
+				res = concatRows(concatRows(x, y, n), res, n)
+			}
+			return res
+		})
+	})
+}
@@ -0,0 +1,58 @@
+package tweeters
+
+import (
+	"math"
+	"testing"
+
+	"github.com/unixpickle/anydiff"
+	"github.com/unixpickle/anyvec/anyvec64"
+)
+
+func TestPairFeatures(t *testing.T) {
+	c := anyvec64.CurrentCreator()
+	x := anydiff.NewConst(c.MakeVectorData([]float64{1, 2, 3, 0}))
+	y := anydiff.NewConst(c.MakeVectorData([]float64{3, -1, 3, 4}))
+	cos1 := 1 / math.Sqrt(5*10)
+	cos2 := 9.0 / 15
+	for _, test := range []struct {
+		Mode     string
+		Expected []float64
+	}{
+		{ConcatFeatures, []float64{1, 2, 3, -1, 3, 0, 3, 4}},
+		{SymmetricFeatures, []float64{3, -2, 2, 3, cos1, 9, 0, 0, 4, cos2}},
+		{AllFeatures, []float64{1, 2, 3, -1, 3, -2, 2, 3, cos1,
+			3, 0, 3, 4, 9, 0, 0, 4, cos2}},
+	} {
+		actual := VectorFloats(pairFeatures(test.Mode, x, y, 2).Output())
+		size, _ := FeatureSize(test.Mode, 2)
+		if len(actual) != 2*size {
+			t.Errorf("%s: expected %d features but got %d", test.Mode, 2*size, len(actual))
+			continue
+		}
+		for i, x := range test.Expected {
+			if math.Abs(actual[i]-x) > 1e-5 {
+				t.Errorf("%s: expected %v but got %v", test.Mode, test.Expected, actual)
+				break
+			}
+		}
+	}
+}
+
+func TestFeaturesModel(t *testing.T) {
+	c := anyvec64.CurrentCreator()
+	arch := &Architecture{Cell: "gru", Layers: 1, Hidden: 8, Pooling: "tail",
+		Aggregator: "mean", Features: SymmetricFeatures, Classifier: []int{4}, Dropout: 1}
+	model, err := arch.NewModel(c, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tweets := randomTweets(4)
+	out := model.Classify(tweets, []int{3, 1})
+	if out.Output().Len() != 1 {
+		t.Errorf("unexpected output size: %d", out.Output().Len())
+	}
+	decoded := checkRoundTrip(t, model, tweets)
+	if decoded.Features != SymmetricFeatures {
+		t.Errorf("unexpected feature mode: %s", decoded.Features)
+	}
+}
@@ -0,0 +1,251 @@
+package tweeters
+
+import (
+	"errors"
+
+	"github.com/unixpickle/anydiff"
+	"github.com/unixpickle/anynet"
+	"github.com/unixpickle/anynet/anyrnn"
+	"github.com/unixpickle/anyvec"
+	"github.com/unixpickle/anyvec/anyvecsave"
+	"github.com/unixpickle/essentials"
+	"github.com/unixpickle/serializer"
+)
+
+func init() {
+	serializer.RegisterTypedDeserializer((&Residual{}).SerializerType(),
+		DeserializeResidual)
+	serializer.RegisterTypedDeserializer((&LayerNorm{}).SerializerType(),
+		DeserializeLayerNorm)
+	serializer.RegisterTypedDeserializer((&Frozen{}).SerializerType(),
+		DeserializeFrozen)
+}
+
+// Residual wraps a block and adds the block's input to
+// its output.
+//
+// The wrapped block's input and output sizes must match.
+type Residual struct {
+	Block anyrnn.Block
+}
+
+// DeserializeResidual deserializes a Residual.
+func DeserializeResidual(d []byte) (*Residual, error) {
+	var block anyrnn.Block
+	if err := serializer.DeserializeAny(d, &block); err != nil {
+		return nil, essentials.AddCtx("deserialize Residual", err)
+	}
+	return &Residual{Block: block}, nil
+}
+
+// Start returns the start state of the wrapped block.
+func (r *Residual) Start(n int) anyrnn.State {
+	return r.Block.Start(n)
+}
+
+// PropagateStart propagates through the wrapped block.
+func (r *Residual) PropagateStart(s anyrnn.StateGrad, g anydiff.Grad) {
+	r.Block.PropagateStart(s, g)
+}
+
+// Step applies the wrapped block and adds in to its
+// output.
+func (r *Residual) Step(s anyrnn.State, in anyvec.Vector) anyrnn.Res {
+	res := r.Block.Step(s, in)
+	out := res.Output().Copy()
+	out.Add(in)
+	return &residualRes{Res: res, out: out}
+}
+
+// Parameters returns the wrapped block's parameters.
+func (r *Residual) Parameters() []*anydiff.Var {
+	return anynet.AllParameters(r.Block)
+}
+
+// SerializerType returns the unique ID used to serialize
+// a Residual with the serializer package.
+func (r *Residual) SerializerType() string {
+	return "github.com/unixpickle/tweeters.Residual"
+}
+
+// Serialize serializes the Residual.
+func (r *Residual) Serialize() ([]byte, error) {
+	return serializer.SerializeAny(r.Block)
+}
+
+type residualRes struct {
+	anyrnn.Res
+	out anyvec.Vector
+}
+
+func (r *residualRes) Output() anyvec.Vector {
+	return r.out
+}
+
+func (r *residualRes) Propagate(u anyvec.Vector, s anyrnn.StateGrad,
+	g anydiff.Grad) (anyvec.Vector, anyrnn.StateGrad) {
+	skip := u.Copy()
+	down, sg := r.Res.Propagate(u, s, g)
+	down.Add(skip)
+	return down, sg
+}
+
+// Frozen wraps a block whose parameters should not be
+// trained.
+//
+// The block behaves exactly like the wrapped block, but
+// its parameters are excluded from
+// Model.TrainableParameters.
+type Frozen struct {
+	Block anyrnn.Block
+}
+
+// DeserializeFrozen deserializes a Frozen.
+func DeserializeFrozen(d []byte) (*Frozen, error) {
+	var block anyrnn.Block
+	if err := serializer.DeserializeAny(d, &block); err != nil {
+		return nil, essentials.AddCtx("deserialize Frozen", err)
+	}
+	return &Frozen{Block: block}, nil
+}
+
+// Start returns the start state of the wrapped block.
+func (f *Frozen) Start(n int) anyrnn.State {
+	return f.Block.Start(n)
+}
+
+// PropagateStart propagates through the wrapped block.
+func (f *Frozen) PropagateStart(s anyrnn.StateGrad, g anydiff.Grad) {
+	f.Block.PropagateStart(s, g)
+}
+
+// Step applies the wrapped block.
+func (f *Frozen) Step(s anyrnn.State, in anyvec.Vector) anyrnn.Res {
+	return f.Block.Step(s, in)
+}
+
+// Parameters returns the wrapped block's parameters.
+func (f *Frozen) Parameters() []*anydiff.Var {
+	return anynet.AllParameters(f.Block)
+}
+
+// SerializerType returns the unique ID used to serialize
+// a Frozen with the serializer package.
+func (f *Frozen) SerializerType() string {
+	return "github.com/unixpickle/tweeters.Frozen"
+}
+
+// Serialize serializes the Frozen.
+func (f *Frozen) Serialize() ([]byte, error) {
+	return serializer.SerializeAny(f.Block)
+}
+
+// LayerNorm is a layer which normalizes each of its input
+// vectors to have zero mean and unit variance, and then
+// applies a learned gain and bias.
+type LayerNorm struct {
+	Gain *anydiff.Var
+	Bias *anydiff.Var
+}
+
+// NewLayerNorm creates a LayerNorm with a gain of 1 and a
+// bias of 0.
+func NewLayerNorm(c anyvec.Creator, size int) *LayerNorm {
+	gain := c.MakeVector(size)
+	gain.AddScalar(c.MakeNumeric(1))
+	return &LayerNorm{
+		Gain: anydiff.NewVar(gain),
+		Bias: anydiff.NewVar(c.MakeVector(size)),
+	}
+}
+
+// DeserializeLayerNorm deserializes a LayerNorm.
+func DeserializeLayerNorm(d []byte) (*LayerNorm, error) {
+	var gain, bias *anyvecsave.S
+	if err := serializer.DeserializeAny(d, &gain, &bias); err != nil {
+		return nil, essentials.AddCtx("deserialize LayerNorm", err)
+	}
+	if gain.Vector.Len() != bias.Vector.Len() {
+		return nil, errors.New("deserialize LayerNorm: gain and bias size mismatch")
+	}
+	return &LayerNorm{
+		Gain: anydiff.NewVar(gain.Vector),
+		Bias: anydiff.NewVar(bias.Vector),
+	}, nil
+}
+
+// Apply normalizes each of the n vectors in the input.
+func (l *LayerNorm) Apply(in anydiff.Res, n int) anydiff.Res {
+	size := l.Gain.Vector.Len()
+	c := in.Output().Creator()
+	scaler := c.MakeNumeric(1 / float64(size))
+	return anydiff.Pool(in, func(in anydiff.Res) anydiff.Res {
+		mean := anydiff.Scale(anydiff.SumCols(&anydiff.Matrix{
+			Data: in,
+			Rows: n,
+			Cols: size,
+		}), scaler)
+		centered := anydiff.Sub(in, repeatCols(mean, n, size))
+		return anydiff.Pool(centered, func(centered anydiff.Res) anydiff.Res {
+			variance := anydiff.Scale(anydiff.SumCols(&anydiff.Matrix{
+				Data: anydiff.Square(centered),
+				Rows: n,
+				Cols: size,
+			}), scaler)
+			invStd := anydiff.Pow(anydiff.AddScalar(variance, c.MakeNumeric(1e-5)),
+				c.MakeNumeric(-0.5))
+			normed := anydiff.Mul(centered, repeatCols(invStd, n, size))
+			return anydiff.AddRepeated(anydiff.ScaleRepeated(normed, l.Gain), l.Bias)
+		})
+	})
+}
+
+// Parameters returns the gain and bias.
+func (l *LayerNorm) Parameters() []*anydiff.Var {
+	return []*anydiff.Var{l.Gain, l.Bias}
+}
+
+// SerializerType returns the unique ID used to serialize
+// a LayerNorm with the serializer package.
+func (l *LayerNorm) SerializerType() string {
+	return "github.com/unixpickle/tweeters.LayerNorm"
+}
+
+// Serialize serializes the LayerNorm.
+func (l *LayerNorm) Serialize() ([]byte, error) {
+	return serializer.SerializeAny(
+		&anyvecsave.S{Vector: l.Gain.Vector},
+		&anyvecsave.S{Vector: l.Bias.Vector},
+	)
+}
+
+// repeatCols turns a vector of n entries into an n-by-cols
+// matrix where every row i is filled with entry i.
+func repeatCols(vec anydiff.Res, n, cols int) anydiff.Res {
+	c := vec.Output().Creator()
+	ones := c.MakeVector(cols)
+	ones.AddScalar(c.MakeNumeric(1))
+	return anydiff.MatMul(false, false,
+		&anydiff.Matrix{Data: vec, Rows: n, Cols: 1},
+		&anydiff.Matrix{Data: anydiff.NewConst(ones), Rows: 1, Cols: cols},
+	).Data
+}
+
+// concatRows concatenates the corresponding rows of two
+// packed lists of n vectors.
+func concatRows(a, b anydiff.Res, n int) anydiff.Res {
+	aCols := a.Output().Len() / n
+	bCols := b.Output().Len() / n
+	table := make([]int, 0, n*(aCols+bCols))
+	for i := 0; i < n; i++ {
+		for j := 0; j < aCols; j++ {
+			table = append(table, i*aCols+j)
+		}
+		for j := 0; j < bCols; j++ {
+			table = append(table, n*aCols+i*bCols+j)
+		}
+	}
+	joined := anydiff.Concat(a, b)
+	mapper := joined.Output().Creator().MakeMapper(joined.Output().Len(), table)
+	return anydiff.Map(mapper, joined)
+}
@@ -0,0 +1,68 @@
+package tweeters
+
+import (
+	"github.com/unixpickle/anydiff"
+)
+
+// LanguageModelCost computes the average cross-entropy of
+// the LMHead's next-token predictions.
+//
+// The predictions are made from the outputs of the
+// forward Encoder at every timestep, so this objective
+// trains the encoder to summarize what it has read.
+// It requires a model with an LMHead and no Conv, since
+// convolutions can see future tokens.
+//
+// The tweets are encoded separately from Encode, so using
+// this as an auxiliary objective requires an additional
+// forward pass.
+func (m *Model) LanguageModelCost(tweets [][]byte) anydiff.Res {
+	if m.LMHead == nil {
+		panic("model has no language model head")
+	}
+	if m.Conv != nil {
+		panic("language modeling requires a causal encoder")
+	}
+	tokens := m.tokenize(tweets)
+	outs := newFlatSeq(m.applyEncoder(m.inputSeq(tokens), m.Encoder))
+	c := outs.Output().Creator()
+	vocabSize := m.LMHead.OutCount
+	outSize := outs.cols()
+
+	var table []int
+	var targets []float64
+	for k, i := range outs.rowTweets {
+		t := outs.rowTimes[k]
+		if t+1 >= len(tokens[i]) {
+			continue
+		}
+		for j := 0; j < outSize; j++ {
+			table = append(table, k*outSize+j)
+		}
+		target := make([]float64, vocabSize)
+		target[tokens[i][t+1].ID] = 1
+		targets = append(targets, target...)
+	}
+	numPredictions := len(targets) / vocabSize
+	if numPredictions == 0 {
+		return anydiff.NewConst(c.MakeVector(1))
+	}
+
+	inputs := anydiff.Map(c.MakeMapper(outs.Output().Len(), table), outs)
+	logits := m.LMHead.Apply(inputs, numPredictions)
+	targetVec := anydiff.NewConst(c.MakeVectorData(c.MakeNumericList(targets)))
+	logProbs := anydiff.Mul(targetVec, anydiff.LogSoftmax(logits, vocabSize))
+	return anydiff.Scale(anydiff.Sum(logProbs), c.MakeNumeric(-1/float64(numPredictions)))
+}
+
+// NumPredictions returns the number of next-token
+// predictions which LanguageModelCost averages over.
+func (m *Model) NumPredictions(tweets [][]byte) int {
+	var res int
+	for _, seq := range m.tokenize(tweets) {
+		if len(seq) > 1 {
+			res += len(seq) - 1
+		}
+	}
+	return res
+}
@@ -0,0 +1,64 @@
+package tweeters
+
+import (
+	"math"
+	"testing"
+
+	"github.com/unixpickle/anyvec/anyvec64"
+)
+
+func TestLanguageModelCost(t *testing.T) {
+	arch := &Architecture{Cell: "lstm", Layers: 2, Hidden: 8, Pooling: "tail",
+		Aggregator: "mean", LMHead: true, Dropout: 1}
+	model, err := arch.NewModel(anyvec64.CurrentCreator(), &CodePointTokenizer{Vocab: 200})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A fresh head predicts a uniform distribution.
+	tweets := append(randomTweets(3), []byte("x"))
+	cost := VectorFloats(model.LanguageModelCost(tweets).Output())[0]
+	if math.Abs(cost-math.Log(200)) > 1e-5 {
+		t.Errorf("expected cost %f but got %f", math.Log(200), cost)
+	}
+
+	if cost := VectorFloats(model.LanguageModelCost([][]byte{{}}).Output())[0]; cost != 0 {
+		t.Errorf("expected zero cost for empty tweet but got %f", cost)
+	}
+
+	decoded := checkRoundTrip(t, model, tweets)
+	if decoded.LMHead == nil {
+		t.Error("language model head was not deserialized")
+	}
+}
+
+func TestLanguageModelTargets(t *testing.T) {
+	c := anyvec64.CurrentCreator()
+	arch := &Architecture{Cell: "lstm", Layers: 1, Hidden: 8, Pooling: "tail",
+		Aggregator: "mean", LMHead: true, Dropout: 1}
+	model, err := arch.NewModel(c, &CodePointTokenizer{Vocab: 200})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The head ignores the encoder and always predicts 'a'
+	// with high confidence.
+	biases := make([]float64, 200)
+	biases['a'] = 5
+	model.LMHead.Biases.Vector.SetData(c.MakeNumericList(biases))
+	logA := 5 - math.Log(math.Exp(5)+199)
+	logOther := -math.Log(math.Exp(5) + 199)
+
+	for _, test := range []struct {
+		Tweet    string
+		Expected float64
+	}{
+		{"aaaa", -logA},
+		{"abab", -(2*logOther + logA) / 3},
+	} {
+		cost := VectorFloats(model.LanguageModelCost([][]byte{[]byte(test.Tweet)}).Output())[0]
+		if math.Abs(cost-test.Expected) > 1e-5 {
+			t.Errorf("%s: expected cost %f but got %f", test.Tweet, test.Expected, cost)
+		}
+	}
+}
@@ -1,6 +1,9 @@
 package tweeters
 
 import (
+	"errors"
+	"sort"
+
 	"github.com/unixpickle/anydiff"
 	"github.com/unixpickle/anydiff/anyseq"
 	"github.com/unixpickle/anynet"
@@ -22,69 +25,408 @@ func init() {
 type Model struct {
 	Encoder    anyrnn.Block
 	Classifier anynet.Net
+
+	// Tokenizer converts tweets into encoder inputs.
+	//
+	// If this is nil, a ByteTokenizer is used.
+	Tokenizer Tokenizer
+
+	// Embedding, if non-nil, is an embedding table created
+	// with NewEmbedding.
+	// Each token is fed to the encoder as its embedding.
+	//
+	// If this is nil, the encoder receives one-hot vectors.
+	Embedding *anynet.FC
+
+	// Backward, if non-nil, is a second encoder which reads
+	// each tweet in reverse.
+	// Its final outputs are concatenated with those of the
+	// Encoder to form the latent vectors.
+	Backward anyrnn.Block
+
+	// Pooler, if non-nil, produces each latent vector from
+	// all of the encoder's outputs.
+	// With a Backward encoder, the same Pooler is applied
+	// to both directions.
+	//
+	// If this is nil, the encoder's final outputs are used.
+	Pooler Pooler
+
+	// Conv, if non-nil, is applied to the inputs before
+	// they are fed to the Encoder (and Backward).
+	// If the Encoder is an empty anyrnn.Stack, the outputs
+	// of Conv are pooled directly.
+	Conv *ConvEncoder
+
+	// Aggregator, if non-nil, combines the latent vectors
+	// of the context tweets before they are compared to a
+	// candidate.
+	// It is used by Classify, but not by Averages.
+	//
+	// If this is nil, the context vectors are averaged.
+	Aggregator Aggregator
+
+	// Features is the feature mode which determines the
+	// Classifier's inputs, such as ConcatFeatures.
+	// An empty string is equivalent to ConcatFeatures.
+	Features string
+
+	// LMHead, if non-nil, predicts the next token from
+	// each output of the Encoder.
+	// It is only used for training; see LanguageModelCost.
+	LMHead *anynet.FC
+
+	// Truncation, if non-nil, is applied to every tweet
+	// before it is tokenized.
+	// Token offsets still refer to the original tweets.
+	//
+	// If this is nil, tweets are not truncated.
+	Truncation *Truncation
 }
 
-// NewModel creates a randomly-initialized model.
-func NewModel(c anyvec.Creator, hidden int, dropout float64) *Model {
-	return &Model{
-		Encoder: anyrnn.Stack{
-			anyrnn.NewLSTM(c, 0x100, hidden).ScaleInWeights(c.MakeNumeric(0x10)),
-			&anyrnn.LayerBlock{
-				Layer: &anynet.Dropout{Enabled: false, KeepProb: dropout},
-			},
-			anyrnn.NewLSTM(c, hidden, hidden).ScaleInWeights(c.MakeNumeric(2)),
-			&anyrnn.LayerBlock{
-				Layer: &anynet.Dropout{Enabled: false, KeepProb: dropout},
-			},
-			anyrnn.NewLSTM(c, hidden, hidden).ScaleInWeights(c.MakeNumeric(2)),
-			&anyrnn.LayerBlock{
-				Layer: &anynet.Dropout{Enabled: false, KeepProb: dropout},
-			},
-		},
-		Classifier: anynet.Net{
-			anynet.NewFC(c, hidden*2, 0x200),
-			anynet.Tanh,
-			anynet.NewFC(c, 0x200, 0x100),
-			anynet.Tanh,
-			anynet.NewFCZero(c, 0x100, 1),
-		},
+// NewModel creates a randomly-initialized model with the
+// default architecture and the given sizes.
+//
+// If tok is nil, the model uses a ByteTokenizer.
+// If embedDim is non-zero, the model uses a learned
+// embedding table of that dimension.
+//
+// For other architectures, see Architecture.NewModel.
+func NewModel(c anyvec.Creator, tok Tokenizer, embedDim, hidden int,
+	dropout float64) *Model {
+	arch := DefaultArchitecture()
+	arch.EmbedDim = embedDim
+	arch.Hidden = hidden
+	arch.Dropout = dropout
+	res, err := arch.NewModel(c, tok)
+	if err != nil {
+		panic(err)
 	}
+	return res
 }
 
 // DeserializeModel deserializes a Model.
-func DeserializeModel(d []byte) (*Model, error) {
-	var res Model
-	if err := serializer.DeserializeAny(d, &res.Encoder, &res.Classifier); err != nil {
-		return nil, essentials.AddCtx("deserialize model", err)
+//
+// Models serialized before optional fields existed are
+// still supported.
+func DeserializeModel(d []byte) (res *Model, err error) {
+	defer essentials.AddCtxTo("deserialize model", &err)
+	objs, err := serializer.DeserializeSlice(d)
+	if err != nil {
+		return nil, err
 	}
-	return &res, nil
+	if len(objs) < 2 || len(objs)%2 != 0 {
+		return nil, errors.New("unexpected number of fields")
+	}
+	res = &Model{}
+	var ok bool
+	if res.Encoder, ok = objs[0].(anyrnn.Block); !ok {
+		return nil, errors.New("invalid encoder type")
+	}
+	if res.Classifier, ok = objs[1].(anynet.Net); !ok {
+		return nil, errors.New("invalid classifier type")
+	}
+	for i := 2; i < len(objs); i += 2 {
+		name, ok := objs[i].(serializer.String)
+		if !ok {
+			return nil, errors.New("invalid field name")
+		}
+		if err := res.setField(string(name), objs[i+1]); err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
 }
 
 // SetDropout enables or disables dropout.
 func (m *Model) SetDropout(enabled bool) {
-	for _, block := range m.Encoder.(anyrnn.Stack) {
-		if layer, ok := block.(*anyrnn.LayerBlock); ok {
-			do := layer.Layer.(*anynet.Dropout)
-			do.Enabled = enabled
-		}
+	for _, do := range m.Dropouts() {
+		do.Enabled = enabled
 	}
 }
 
+// Dropouts returns all of the dropout layers in the
+// encoders.
+func (m *Model) Dropouts() []*anynet.Dropout {
+	res := findDropouts(m.Encoder)
+	if m.Backward != nil {
+		res = append(res, findDropouts(m.Backward)...)
+	}
+	return res
+}
+
 // Encode produces latent vectors for all of the tweets.
 // The latent vectors are packed into a single result.
+//
+// Internally, the tweets are encoded from longest to
+// shortest, so that the tweets which are still present
+// at each timestep form a contiguous prefix of the batch.
 func (m *Model) Encode(tweets [][]byte) anydiff.Res {
+	tokens := m.tokenize(tweets)
+	order := lengthOrder(tokens)
+	sorted := make([][]Token, len(tokens))
+	for i, j := range order {
+		sorted[i] = tokens[j]
+	}
+	var backward anyseq.Seq
+	if m.Backward != nil {
+		backward = m.inputSeq(reverseTokens(sorted))
+	}
+	latent := m.encodeSeq(m.inputSeq(sorted), backward, len(tweets))
+	return unsortRows(latent, order)
+}
+
+// Averages is like Encode, but it averages groups of
+// latent vectors.
+//
+// The avgSizes slice specifies the size for each average.
+// Averages are always taken over consecutive vectors.
+// For example, if avgSizes is [1, 3, 2], then the first
+// vector, an average of the next three vectors, and an
+// average of the next two vectors are returned.
+// The sum of all the average sizes should equal the total
+// number of tweets.
+func (m *Model) Averages(tweets [][]byte, avgSizes []int) anydiff.Res {
+	return averageLatent(m.Encode(tweets), len(tweets), avgSizes)
+}
+
+// Classify computes the classifier's same-author logits.
+//
+// The tweets and avgSizes are laid out as for Averages,
+// where each pair of groups is a set of context tweets
+// followed by a single candidate tweet.
+func (m *Model) Classify(tweets [][]byte, avgSizes []int) anydiff.Res {
+	return m.classifyLatent(m.Encode(tweets), len(tweets), avgSizes)
+}
+
+// PairwiseLogits computes the classifier's same-author
+// logits for every combination of context and candidate.
+//
+// The tweets and avgSizes are laid out as for Classify.
+// For n pairs, the result is an n-by-n matrix, where
+// entry (i, j) compares context i to candidate j.
+func (m *Model) PairwiseLogits(tweets [][]byte, avgSizes []int) anydiff.Res {
+	return anydiff.Pool(m.Encode(tweets), func(latent anydiff.Res) anydiff.Res {
+		contexts, sizes, candidates := splitPairs(latent, len(tweets), avgSizes)
+		var allContexts, allCandidates []anydiff.Res
+		var allSizes []int
+		for i, context := range contexts {
+			for _, candidate := range candidates {
+				allContexts = append(allContexts, context)
+				allCandidates = append(allCandidates, candidate)
+				allSizes = append(allSizes, sizes[i])
+			}
+		}
+		return m.compare(anydiff.Concat(allContexts...), allSizes,
+			anydiff.Concat(allCandidates...))
+	})
+}
+
+// Parameters returns the model's parameters.
+//
+// This is the union of EncoderParameters and
+// ClassifierParameters.
+func (m *Model) Parameters() []*anydiff.Var {
+	return append(m.EncoderParameters(), m.ClassifierParameters()...)
+}
+
+// EncoderParameters returns the parameters involved in
+// turning tweets into latent vectors, including those of
+// the language model head.
+func (m *Model) EncoderParameters() []*anydiff.Var {
+	objs := []interface{}{m.Encoder}
+	if m.Embedding != nil {
+		objs = append(objs, m.Embedding)
+	}
+	if m.Backward != nil {
+		objs = append(objs, m.Backward)
+	}
+	if m.Pooler != nil {
+		objs = append(objs, m.Pooler)
+	}
+	if m.Conv != nil {
+		objs = append(objs, m.Conv)
+	}
+	if m.LMHead != nil {
+		objs = append(objs, m.LMHead)
+	}
+	return collectParameters(objs)
+}
+
+// ClassifierParameters returns the parameters involved in
+// comparing latent vectors, namely those of the
+// aggregator and the classifier.
+func (m *Model) ClassifierParameters() []*anydiff.Var {
+	objs := []interface{}{m.Classifier}
+	if m.Aggregator != nil {
+		objs = append(objs, m.Aggregator)
+	}
+	return collectParameters(objs)
+}
+
+// TrainableParameters is like Parameters, but it omits
+// the parameters of Frozen blocks.
+func (m *Model) TrainableParameters() []*anydiff.Var {
+	frozen := anydiff.NewVarSet(frozenParameters(m.Encoder)...)
+	if m.Backward != nil {
+		for _, p := range frozenParameters(m.Backward) {
+			frozen.Add(p)
+		}
+	}
+	var res []*anydiff.Var
+	for _, p := range m.Parameters() {
+		if !frozen.Has(p) {
+			res = append(res, p)
+		}
+	}
+	return res
+}
+
+// SerializerType returns the unique ID used to serialize
+// a Model with the serializer package.
+func (m *Model) SerializerType() string {
+	return "github.com/unixpickle/tweeters.Model"
+}
+
+// Serialize serializes the Model.
+//
+// The encoder and classifier are followed by name-value
+// pairs for every optional field that is set.
+func (m *Model) Serialize() ([]byte, error) {
+	objs := []interface{}{m.Encoder, m.Classifier}
+	if m.Tokenizer != nil {
+		objs = append(objs, serializer.String("Tokenizer"), m.Tokenizer)
+	}
+	if m.Embedding != nil {
+		objs = append(objs, serializer.String("Embedding"), m.Embedding)
+	}
+	if m.Backward != nil {
+		objs = append(objs, serializer.String("Backward"), m.Backward)
+	}
+	if m.Pooler != nil {
+		objs = append(objs, serializer.String("Pooler"), m.Pooler)
+	}
+	if m.Conv != nil {
+		objs = append(objs, serializer.String("Conv"), m.Conv)
+	}
+	if m.Aggregator != nil {
+		objs = append(objs, serializer.String("Aggregator"), m.Aggregator)
+	}
+	if m.Features != "" {
+		objs = append(objs, serializer.String("Features"), serializer.String(m.Features))
+	}
+	if m.LMHead != nil {
+		objs = append(objs, serializer.String("LMHead"), m.LMHead)
+	}
+	if m.Truncation != nil {
+		objs = append(objs, serializer.String("Truncation"), m.Truncation)
+	}
+	return serializer.SerializeAny(objs...)
+}
+
+func (m *Model) setField(name string, obj serializer.Serializer) error {
+	var ok bool
+	switch name {
+	case "Tokenizer":
+		m.Tokenizer, ok = obj.(Tokenizer)
+	case "Embedding":
+		m.Embedding, ok = obj.(*anynet.FC)
+	case "Backward":
+		m.Backward, ok = obj.(anyrnn.Block)
+	case "Pooler":
+		m.Pooler, ok = obj.(Pooler)
+	case "Conv":
+		m.Conv, ok = obj.(*ConvEncoder)
+	case "Aggregator":
+		m.Aggregator, ok = obj.(Aggregator)
+	case "Features":
+		var features serializer.String
+		features, ok = obj.(serializer.String)
+		m.Features = string(features)
+	case "LMHead":
+		m.LMHead, ok = obj.(*anynet.FC)
+	case "Truncation":
+		m.Truncation, ok = obj.(*Truncation)
+	default:
+		return errors.New("unknown field: " + name)
+	}
+	if !ok {
+		return errors.New("invalid type for field: " + name)
+	}
+	return nil
+}
+
+func (m *Model) tokenizer() Tokenizer {
+	if m.Tokenizer == nil {
+		return &ByteTokenizer{}
+	}
+	return m.Tokenizer
+}
+
+// tokenize converts every tweet into tokens, applying
+// the model's truncation.
+func (m *Model) tokenize(tweets [][]byte) [][]Token {
+	tok := m.tokenizer()
+	res := make([][]Token, len(tweets))
+	for i, tweet := range tweets {
+		if m.Truncation != nil {
+			res[i] = m.Truncation.truncateTokens(tok, tweet)
+		} else {
+			res[i] = tok.Tokenize(tweet)
+		}
+	}
+	return res
+}
+
+// inputSeq converts tokenized tweets into an input
+// sequence for the encoder.
+func (m *Model) inputSeq(tokens [][]Token) anyseq.Seq {
+	if m.Embedding == nil {
+		return anyseq.ConstSeq(m.creator(), m.oneHotBatches(tokens))
+	}
+	var present [][]bool
+	var embedded []anydiff.Res
+	for idx := 0; ; idx++ {
+		var pres []bool
+		var ids []int
+		for _, seq := range tokens {
+			pres = append(pres, idx < len(seq))
+			if idx < len(seq) {
+				ids = append(ids, seq[idx].ID)
+			}
+		}
+		if len(ids) == 0 {
+			break
+		}
+		present = append(present, pres)
+		embedded = append(embedded, embedTokens(m.Embedding, ids))
+	}
+	return newResSeq(m.creator(), present, embedded)
+}
+
+// inputBatches computes the encoder's inputs without
+// tracking gradients through the embedding table.
+func (m *Model) inputBatches(tokens [][]Token) []*anyseq.Batch {
+	if m.Embedding == nil {
+		return m.oneHotBatches(tokens)
+	}
+	return m.inputSeq(tokens).Output()
+}
+
+func (m *Model) oneHotBatches(tokens [][]Token) []*anyseq.Batch {
 	creator := m.creator()
+	vocabSize := m.tokenizer().VocabSize()
 	var batches []*anyseq.Batch
 	var idx int
 	for {
 		var oneHot []float64
 		var present []bool
-		for _, tweet := range tweets {
-			pres := idx < len(tweet)
+		for _, seq := range tokens {
+			pres := idx < len(seq)
 			present = append(present, pres)
 			if pres {
-				oh := make([]float64, 0x100)
-				oh[tweet[idx]] = 1
+				oh := make([]float64, vocabSize)
+				oh[seq[idx].ID] = 1
 				oneHot = append(oneHot, oh...)
 			}
 		}
@@ -97,42 +439,95 @@ func (m *Model) Encode(tweets [][]byte) anydiff.Res {
 		})
 		idx++
 	}
-	constIn := anyseq.ConstSeq(creator, batches)
-	return anyseq.Tail(anyrnn.Map(constIn, m.Encoder))
+	return batches
 }
 
-// Averages is like Encode, but it averages groups of
-// latent vectors.
+// encodeSeq applies the encoders to input sequences and
+// produces a packed latent vector for each tweet.
 //
-// The avgSizes slice specifies the size for each average.
-// Averages are always taken over consecutive vectors.
-// For example, if avgSizes is [1, 3, 2], then the first
-// vector, an average of the next three vectors, and an
-// average of the next two vectors are returned.
-// The sum of all the average sizes should equal the total
-// number of tweets.
-func (m *Model) Averages(tweets [][]byte, avgSizes []int) anydiff.Res {
-	latent := m.Encode(tweets)
-	return anydiff.Pool(latent, func(latent anydiff.Res) anydiff.Res {
-		latentSize := latent.Output().Len() / len(tweets)
-		offset := 0
-		var res []anydiff.Res
-		for _, size := range avgSizes {
-			subset := anydiff.Slice(latent, offset, offset+size*latentSize)
-			offset += size * latentSize
-			mat := &anydiff.Matrix{Data: subset, Rows: size, Cols: latentSize}
-			sum := anydiff.SumRows(mat)
-			divisor := sum.Output().Creator().MakeNumeric(1 / float64(size))
-			res = append(res, anydiff.Scale(sum, divisor))
-		}
-		return anydiff.Concat(res...)
+// The backward sequence should contain the reversed
+// inputs, and it is ignored if there is no Backward
+// encoder.
+func (m *Model) encodeSeq(forward, backward anyseq.Seq, n int) anydiff.Res {
+	latent := m.pool(m.applyEncoder(forward, m.Encoder), n)
+	if m.Backward == nil {
+		return latent
+	}
+	return concatRows(latent, m.pool(m.applyEncoder(backward, m.Backward), n), n)
+}
+
+// applyEncoder applies the convolutional encoder (if
+// there is one) and a recurrent encoder to an input
+// sequence.
+func (m *Model) applyEncoder(in anyseq.Seq, block anyrnn.Block) anyseq.Seq {
+	if m.Conv != nil {
+		in = m.Conv.Apply(in)
+	}
+	if stack, ok := block.(anyrnn.Stack); ok && len(stack) == 0 {
+		return in
+	}
+	return anyrnn.Map(in, block)
+}
+
+func (m *Model) pool(outputs anyseq.Seq, n int) anydiff.Res {
+	if m.Pooler == nil {
+		return anyseq.Tail(outputs)
+	}
+	return m.Pooler.Pool(outputs, n)
+}
+
+// lengthOrder returns the indices of the sequences,
+// sorted from longest to shortest.
+func lengthOrder(tokens [][]Token) []int {
+	order := make([]int, len(tokens))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return len(tokens[order[i]]) > len(tokens[order[j]])
 	})
+	return order
 }
 
-// Parameters returns the model's parameters.
-func (m *Model) Parameters() []*anydiff.Var {
+// unsortRows undoes a permutation of packed rows, where
+// row i of rows came from row order[i] of the original.
+func unsortRows(rows anydiff.Res, order []int) anydiff.Res {
+	sorted := true
+	for i, j := range order {
+		if i != j {
+			sorted = false
+			break
+		}
+	}
+	if sorted {
+		return rows
+	}
+	cols := rows.Output().Len() / len(order)
+	table := make([]int, len(order)*cols)
+	for i, j := range order {
+		for k := 0; k < cols; k++ {
+			table[j*cols+k] = i*cols + k
+		}
+	}
+	mapper := rows.Output().Creator().MakeMapper(rows.Output().Len(), table)
+	return anydiff.Map(mapper, rows)
+}
+
+// reverseTokens reverses each sequence of tokens.
+func reverseTokens(tokens [][]Token) [][]Token {
+	res := make([][]Token, len(tokens))
+	for i, seq := range tokens {
+		res[i] = make([]Token, len(seq))
+		for j, tok := range seq {
+			res[i][len(seq)-(j+1)] = tok
+		}
+	}
+	return res
+}
+
+func collectParameters(objs []interface{}) []*anydiff.Var {
 	var res []*anydiff.Var
-	for _, obj := range []interface{}{m.Encoder, m.Classifier} {
+	for _, obj := range objs {
 		if p, ok := obj.(anynet.Parameterizer); ok {
 			res = append(res, p.Parameters()...)
 		}
@@ -140,15 +535,133 @@ func (m *Model) Parameters() []*anydiff.Var {
 	return res
 }
 
-// SerializerType returns the unique ID used to serialize
-// a Model with the serializer package.
-func (m *Model) SerializerType() string {
-	return "github.com/unixpickle/tweeters.Model"
+// findDropouts finds every dropout layer in a block,
+// including those in nested blocks.
+func findDropouts(block anyrnn.Block) []*anynet.Dropout {
+	switch block := block.(type) {
+	case anyrnn.Stack:
+		var res []*anynet.Dropout
+		for _, sub := range block {
+			res = append(res, findDropouts(sub)...)
+		}
+		return res
+	case *Residual:
+		return findDropouts(block.Block)
+	case *Frozen:
+		return findDropouts(block.Block)
+	case *anyrnn.LayerBlock:
+		if do, ok := block.Layer.(*anynet.Dropout); ok {
+			return []*anynet.Dropout{do}
+		}
+	}
+	return nil
 }
 
-// Serialize serializes the Model.
-func (m *Model) Serialize() ([]byte, error) {
-	return serializer.SerializeAny(m.Encoder, m.Classifier)
+// frozenParameters finds the parameters of every Frozen
+// block within a block.
+func frozenParameters(block anyrnn.Block) []*anydiff.Var {
+	switch block := block.(type) {
+	case anyrnn.Stack:
+		var res []*anydiff.Var
+		for _, sub := range block {
+			res = append(res, frozenParameters(sub)...)
+		}
+		return res
+	case *Residual:
+		return frozenParameters(block.Block)
+	case *Frozen:
+		return block.Parameters()
+	}
+	return nil
+}
+
+// classifyLatent is like Classify, but it takes the
+// encoded tweets.
+func (m *Model) classifyLatent(latent anydiff.Res, numTweets int, avgSizes []int) anydiff.Res {
+	return anydiff.Pool(latent, func(latent anydiff.Res) anydiff.Res {
+		contexts, sizes, candidates := splitPairs(latent, numTweets, avgSizes)
+		return m.compare(anydiff.Concat(contexts...), sizes, anydiff.Concat(candidates...))
+	})
+}
+
+// splitPairs splits encoded tweets into groups of context
+// vectors and candidate vectors.
+//
+// The tweets and avgSizes are laid out as for Classify.
+func splitPairs(latent anydiff.Res, numTweets int, avgSizes []int) (contexts []anydiff.Res,
+	sizes []int, candidates []anydiff.Res) {
+	if len(avgSizes)%2 != 0 {
+		panic("average sizes must come in pairs")
+	}
+	latentSize := latent.Output().Len() / numTweets
+	var offset int
+	for i := 0; i < len(avgSizes); i += 2 {
+		if avgSizes[i+1] != 1 {
+			panic("each candidate must be a single tweet")
+		}
+		end := offset + avgSizes[i]*latentSize
+		contexts = append(contexts, anydiff.Slice(latent, offset, end))
+		candidates = append(candidates, anydiff.Slice(latent, end, end+latentSize))
+		sizes = append(sizes, avgSizes[i])
+		offset = end + latentSize
+	}
+	return
+}
+
+// compare computes same-author logits for a list of
+// candidate vectors, each with its own group of context
+// vectors.
+//
+// The contexts contain all of the packed context vectors,
+// and sizes specifies the number of context vectors for
+// each candidate.
+func (m *Model) compare(contexts anydiff.Res, sizes []int, candidates anydiff.Res) anydiff.Res {
+	n := len(sizes)
+	return anydiff.Pool(candidates, func(candidates anydiff.Res) anydiff.Res {
+		users := m.aggregate(contexts, sizes, candidates)
+		return m.Classifier.Apply(pairFeatures(m.Features, users, candidates, n), n)
+	})
+}
+
+// aggregate combines each group of context vectors into a
+// single vector.
+func (m *Model) aggregate(contexts anydiff.Res, sizes []int, candidates anydiff.Res) anydiff.Res {
+	var numContexts int
+	for _, size := range sizes {
+		numContexts += size
+	}
+	if m.Aggregator == nil {
+		return averageLatent(contexts, numContexts, sizes)
+	}
+	latentSize := candidates.Output().Len() / len(sizes)
+	return anydiff.Pool(contexts, func(contexts anydiff.Res) anydiff.Res {
+		var res []anydiff.Res
+		var offset int
+		for i, size := range sizes {
+			context := anydiff.Slice(contexts, offset*latentSize, (offset+size)*latentSize)
+			candidate := anydiff.Slice(candidates, i*latentSize, (i+1)*latentSize)
+			res = append(res, m.Aggregator.Aggregate(context, size, candidate))
+			offset += size
+		}
+		return anydiff.Concat(res...)
+	})
+}
+
+func averageLatent(latent anydiff.Res, numTweets int, avgSizes []int) anydiff.Res {
+	return anydiff.Pool(latent, func(latent anydiff.Res) anydiff.Res {
+		latentSize := latent.Output().Len() / numTweets
+		offset := 0
+		var res []anydiff.Res
+		for _, size := range avgSizes {
+			subset := anydiff.Slice(latent, offset, offset+size*latentSize)
+			offset += size * latentSize
+			mat := &anydiff.Matrix{Data: subset, Rows: size, Cols: latentSize}
+			sum := anydiff.SumRows(mat)
+			divisor := sum.Output().Creator().MakeNumeric(1 / float64(size))
+			res = append(res, anydiff.Scale(sum, divisor))
+		}
+		return anydiff.Concat(res...)
+	})
 }
 
 func (m *Model) creator() anyvec.Creator {
@@ -0,0 +1,162 @@
+package tweeters
+
+import (
+	"math"
+	"sort"
+	"testing"
+
+	"github.com/unixpickle/anydiff"
+	"github.com/unixpickle/anyvec/anyvec64"
+	"github.com/unixpickle/serializer"
+)
+
+func TestPairwiseLogits(t *testing.T) {
+	model := NewModel(anyvec64.CurrentCreator(), nil, 0, 16, 1)
+	contexts := [][][]byte{randomTweets(2), randomTweets(3), randomTweets(1)}
+	candidates := randomTweets(3)
+
+	var tweets [][]byte
+	var avg []int
+	for i, context := range contexts {
+		tweets = append(append(tweets, context...), candidates[i])
+		avg = append(avg, len(context), 1)
+	}
+	actual := VectorFloats(model.PairwiseLogits(tweets, avg).Output())
+	if len(actual) != 9 {
+		t.Fatalf("expected 9 logits but got %d", len(actual))
+	}
+	for i, context := range contexts {
+		expected := NewContext(model, context).Score(candidates, 2)
+		for j, x := range expected {
+			if math.Abs(actual[i*3+j]-x) > 1e-5 {
+				t.Errorf("entry (%d, %d): expected %f but got %f", i, j, x, actual[i*3+j])
+			}
+		}
+	}
+}
+
+func TestParameterGroups(t *testing.T) {
+	arch := &Architecture{Cell: "gru", Layers: 1, Hidden: 8, EmbedDim: 4,
+		Aggregator: "attention", Pooling: "attention", Dropout: 1}
+	model, err := arch.NewModel(anyvec64.CurrentCreator(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	encoder := anydiff.NewVarSet(model.EncoderParameters()...)
+	classifier := anydiff.NewVarSet(model.ClassifierParameters()...)
+	for _, p := range model.Parameters() {
+		if encoder.Has(p) == classifier.Has(p) {
+			t.Fatal("parameter groups should partition the parameters")
+		}
+	}
+	if !encoder.Has(model.Embedding.Weights) {
+		t.Error("embedding should be in the encoder group")
+	}
+	for _, p := range model.Aggregator.(*AttentionAggregator).Parameters() {
+		if !classifier.Has(p) {
+			t.Error("aggregator should be in the classifier group")
+		}
+	}
+}
+
+func TestEncodeOrder(t *testing.T) {
+	arch := &Architecture{Cell: "lstm", Layers: 1, Hidden: 8, Bidirectional: true,
+		Pooling: "mean", Dropout: 1}
+	model, err := arch.NewModel(anyvec64.CurrentCreator(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tweets := randomTweets(5)
+	actual := VectorFloats(model.Encode(tweets).Output())
+	size := arch.LatentSize()
+	for i, tweet := range tweets {
+		expected := VectorFloats(model.Encode([][]byte{tweet}).Output())
+		for j, x := range expected {
+			if math.Abs(actual[i*size+j]-x) > 1e-8 {
+				t.Fatalf("tweet %d: expected %v but got %v", i, expected,
+					actual[i*size:(i+1)*size])
+			}
+		}
+	}
+}
+
+func TestLegacyModel(t *testing.T) {
+	model := NewModel(anyvec64.CurrentCreator(), nil, 0, 16, 1)
+
+	// Models used to be serialized as just an encoder and
+	// a classifier.
+	data, err := serializer.SerializeAny(model.Encoder, model.Classifier)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := DeserializeModel(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Tokenizer != nil || decoded.Embedding != nil || decoded.Backward != nil ||
+		decoded.Pooler != nil || decoded.Conv != nil || decoded.Aggregator != nil ||
+		decoded.LMHead != nil || decoded.Truncation != nil {
+		t.Errorf("unexpected optional fields: %+v", decoded)
+	}
+	if decoded.Features != "" {
+		t.Errorf("unexpected feature mode: %s", decoded.Features)
+	}
+	checkEquivalent(t, model, decoded, randomTweets(4))
+}
+
+func BenchmarkEncode(b *testing.B) {
+	model := NewModel(anyvec64.CurrentCreator(), nil, 0, 64, 1)
+	tweets := randomTweets(64)
+	sorted := append([][]byte{}, tweets...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return len(sorted[i]) < len(sorted[j])
+	})
+	for _, mode := range []struct {
+		name   string
+		tweets [][]byte
+	}{{"Random", tweets}, {"Bucketed", sorted}} {
+		b.Run(mode.name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				for j := 0; j < len(mode.tweets); j += 16 {
+					model.Encode(mode.tweets[j : j+16])
+				}
+			}
+		})
+	}
+}
+
+// checkRoundTrip serializes and deserializes a model and
+// checks that the result is equivalent to the original.
+func checkRoundTrip(t *testing.T, model *Model, tweets [][]byte) *Model {
+	data, err := serializer.SerializeAny(model)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded *Model
+	if err := serializer.DeserializeAny(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	checkEquivalent(t, model, decoded, tweets)
+	return decoded
+}
+
+// checkEquivalent checks that two models produce the same
+// latent vectors and logits for the tweets.
+func checkEquivalent(t *testing.T, expected, actual *Model, tweets [][]byte) {
+	avg := []int{len(tweets) - 1, 1}
+	for _, outs := range [][2]anydiff.Res{
+		{expected.Encode(tweets), actual.Encode(tweets)},
+		{expected.Classify(tweets, avg), actual.Classify(tweets, avg)},
+	} {
+		exp := VectorFloats(outs[0].Output())
+		act := VectorFloats(outs[1].Output())
+		if len(exp) != len(act) {
+			t.Fatalf("expected %d outputs but got %d", len(exp), len(act))
+		}
+		for i, x := range exp {
+			if math.Abs(x-act[i]) > 1e-8 {
+				t.Fatalf("output %d: expected %f but got %f", i, x, act[i])
+			}
+		}
+	}
+}
@@ -0,0 +1,456 @@
+// Command modeltool inspects and modifies serialized
+// models.
+//
+// Usage:
+//
+//	modeltool <command> [flags]
+//
+// The available commands are inspect, classifier, freeze,
+// unfreeze, dropout, truncate, and convert.
+// Run a command with -help to see its flags.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/unixpickle/anydiff"
+	"github.com/unixpickle/anynet"
+	"github.com/unixpickle/anynet/anyrnn"
+	"github.com/unixpickle/anyvec"
+	"github.com/unixpickle/anyvec/anyvec32"
+	"github.com/unixpickle/anyvec/anyvec64"
+	"github.com/unixpickle/essentials"
+	"github.com/unixpickle/serializer"
+	"github.com/unixpickle/tweeters"
+)
+
+var commands = map[string]func(args []string){
+	"inspect":    inspect,
+	"classifier": classifier,
+	"freeze":     func(args []string) { freeze(args, true) },
+	"unfreeze":   func(args []string) { freeze(args, false) },
+	"dropout":    dropout,
+	"truncate":   truncate,
+	"convert":    convert,
+}
+
+func main() {
+	if len(os.Args) < 2 || commands[os.Args[1]] == nil {
+		fmt.Fprintln(os.Stderr, "Usage: modeltool <command> [flags]")
+		fmt.Fprintln(os.Stderr)
+		fmt.Fprintln(os.Stderr, "Commands:")
+		fmt.Fprintln(os.Stderr, "  inspect     print the structure of a model")
+		fmt.Fprintln(os.Stderr, "  classifier  replace or reinitialize the classifier")
+		fmt.Fprintln(os.Stderr, "  freeze      freeze encoder layers")
+		fmt.Fprintln(os.Stderr, "  unfreeze    unfreeze encoder layers")
+		fmt.Fprintln(os.Stderr, "  dropout     change the dropout keep probability")
+		fmt.Fprintln(os.Stderr, "  truncate    change the maximum tweet length")
+		fmt.Fprintln(os.Stderr, "  convert     convert between float32 and float64")
+		os.Exit(1)
+	}
+	commands[os.Args[1]](os.Args[2:])
+}
+
+func inspect(args []string) {
+	var inPath string
+	fs := flag.NewFlagSet("inspect", flag.ExitOnError)
+	fs.StringVar(&inPath, "in", "", "path to model")
+	fs.Parse(args)
+	model := loadModel(inPath)
+
+	fmt.Println("Numeric type:", numericType(model))
+	if model.Tokenizer != nil {
+		fmt.Printf("Tokenizer: %T (vocab %d)\n", model.Tokenizer, model.Tokenizer.VocabSize())
+	} else {
+		fmt.Println("Tokenizer: default")
+	}
+	if model.Truncation != nil {
+		fmt.Printf("Truncation: %s (max %d bytes)\n", model.Truncation.Strategy,
+			model.Truncation.MaxLen)
+	} else {
+		fmt.Println("Truncation: none")
+	}
+	if model.Embedding != nil {
+		fmt.Println("Embedding:", describeFC(model.Embedding))
+	}
+	if model.Conv != nil {
+		fmt.Printf("Conv: %d layers\n", len(model.Conv.Layers))
+		for i, layer := range model.Conv.Layers {
+			fmt.Printf("  [%d] width=%d dilation=%d values=%s\n", i, layer.Width,
+				layer.Dilation, describeFC(layer.Values))
+		}
+	}
+	printBlock(model.Encoder, "", "Encoder: ", false)
+	if model.Backward != nil {
+		printBlock(model.Backward, "", "Backward: ", false)
+	}
+	if model.Pooler != nil {
+		fmt.Printf("Pooler: %T (%d params)\n", model.Pooler,
+			countParams(anynet.AllParameters(model.Pooler)))
+	} else {
+		fmt.Println("Pooler: tail")
+	}
+	if model.Aggregator != nil {
+		fmt.Printf("Aggregator: %T (%d params)\n", model.Aggregator,
+			countParams(anynet.AllParameters(model.Aggregator)))
+	} else {
+		fmt.Println("Aggregator: mean")
+	}
+	features := model.Features
+	if features == "" {
+		features = tweeters.ConcatFeatures
+	}
+	fmt.Println("Features:", features)
+	fmt.Println("Classifier:")
+	for i, layer := range model.Classifier {
+		if fc, ok := layer.(*anynet.FC); ok {
+			fmt.Printf("  [%d] %s\n", i, describeFC(fc))
+		} else {
+			fmt.Printf("  [%d] %T\n", i, layer)
+		}
+	}
+	if model.LMHead != nil {
+		fmt.Println("LM head:", describeFC(model.LMHead))
+	}
+	fmt.Printf("Parameters: %d (%d trainable)\n", countParams(model.Parameters()),
+		countParams(model.TrainableParameters()))
+}
+
+func classifier(args []string) {
+	var inPath, outPath string
+	var sizes, features string
+	fs := flag.NewFlagSet("classifier", flag.ExitOnError)
+	fs.StringVar(&inPath, "in", "", "path to input model")
+	fs.StringVar(&outPath, "out", "", "path to output model")
+	fs.StringVar(&sizes, "sizes", "",
+		"comma-separated hidden layer sizes (default: keep current sizes)")
+	fs.StringVar(&features, "features", "",
+		"classifier features (default: keep current features)")
+	fs.Parse(args)
+	model := loadModel(inPath)
+	checkOut(outPath)
+
+	var hidden []int
+	var err error
+	if sizes != "" {
+		hidden, err = parseSizes(sizes)
+	} else {
+		hidden, err = classifierSizes(model)
+	}
+	if err == nil {
+		err = replaceClassifier(model, features, hidden)
+	}
+	if err != nil {
+		essentials.Die(err)
+	}
+	saveModel(outPath, model)
+}
+
+func freeze(args []string, frozen bool) {
+	var inPath, outPath string
+	var layers string
+	fs := flag.NewFlagSet("freeze", flag.ExitOnError)
+	fs.StringVar(&inPath, "in", "", "path to input model")
+	fs.StringVar(&outPath, "out", "", "path to output model")
+	fs.StringVar(&layers, "layers", "",
+		"comma-separated encoder stack indices (default: all)")
+	fs.Parse(args)
+	model := loadModel(inPath)
+	checkOut(outPath)
+
+	var indices []int
+	if layers != "" {
+		var err error
+		indices, err = parseSizes(layers)
+		if err != nil {
+			essentials.Die(err)
+		}
+	}
+	var err error
+	model.Encoder, err = freezeStack(model.Encoder, indices, frozen)
+	if err == nil && model.Backward != nil {
+		model.Backward, err = freezeStack(model.Backward, indices, frozen)
+	}
+	if err != nil {
+		essentials.Die(err)
+	}
+	saveModel(outPath, model)
+}
+
+func dropout(args []string) {
+	var inPath, outPath string
+	var keepProb float64
+	fs := flag.NewFlagSet("dropout", flag.ExitOnError)
+	fs.StringVar(&inPath, "in", "", "path to input model")
+	fs.StringVar(&outPath, "out", "", "path to output model")
+	fs.Float64Var(&keepProb, "keep", 1, "dropout keep probability")
+	fs.Parse(args)
+	model := loadModel(inPath)
+	checkOut(outPath)
+
+	if keepProb <= 0 || keepProb > 1 {
+		essentials.Die("Invalid keep probability:", keepProb)
+	}
+	dropouts := model.Dropouts()
+	if len(dropouts) == 0 {
+		essentials.Die("Model has no dropout layers.")
+	}
+	for _, do := range dropouts {
+		do.KeepProb = keepProb
+	}
+	saveModel(outPath, model)
+}
+
+func truncate(args []string) {
+	var inPath, outPath string
+	var maxLen int
+	var strategy string
+	fs := flag.NewFlagSet("truncate", flag.ExitOnError)
+	fs.StringVar(&inPath, "in", "", "path to input model")
+	fs.StringVar(&outPath, "out", "", "path to output model")
+	fs.IntVar(&maxLen, "maxlen", 0, "maximum tweet length in bytes (0 for no limit)")
+	fs.StringVar(&strategy, "strategy", tweeters.HeadTruncation,
+		"truncation strategy (head, tail, or middle)")
+	fs.Parse(args)
+	model := loadModel(inPath)
+	checkOut(outPath)
+
+	var err error
+	model.Truncation, err = tweeters.NewTruncation(maxLen, strategy)
+	if err != nil {
+		essentials.Die(err)
+	}
+	saveModel(outPath, model)
+}
+
+func convert(args []string) {
+	var inPath, outPath string
+	var numType string
+	fs := flag.NewFlagSet("convert", flag.ExitOnError)
+	fs.StringVar(&inPath, "in", "", "path to input model")
+	fs.StringVar(&outPath, "out", "", "path to output model")
+	fs.StringVar(&numType, "type", "float32", "numeric type (float32 or float64)")
+	fs.Parse(args)
+	model := loadModel(inPath)
+	checkOut(outPath)
+
+	var c anyvec.Creator
+	switch numType {
+	case "float32":
+		c = anyvec32.CurrentCreator()
+	case "float64":
+		c = anyvec64.CurrentCreator()
+	default:
+		essentials.Die("Unknown numeric type:", numType)
+	}
+	convertModel(model, c)
+	saveModel(outPath, model)
+}
+
+func loadModel(path string) *tweeters.Model {
+	if path == "" {
+		essentials.Die("Required flag: -in. See -help.")
+	}
+	var model *tweeters.Model
+	if err := serializer.LoadAny(path, &model); err != nil {
+		essentials.Die(err)
+	}
+	return model
+}
+
+func checkOut(path string) {
+	if path == "" {
+		essentials.Die("Required flag: -out. See -help.")
+	}
+}
+
+func saveModel(path string, model *tweeters.Model) {
+	if err := serializer.SaveAny(path, model); err != nil {
+		essentials.Die(err)
+	}
+}
+
+// printBlock prints a block tree, one line per block,
+// labeling the elements of stacks with their indices.
+func printBlock(block anyrnn.Block, indent, label string, frozen bool) {
+	prefix := indent + label
+	switch b := block.(type) {
+	case anyrnn.Stack:
+		fmt.Println(prefix + "Stack")
+		for i, sub := range b {
+			printBlock(sub, indent+"  ", fmt.Sprintf("[%d] ", i), frozen)
+		}
+	case *tweeters.Frozen:
+		printBlock(b.Block, indent, label, true)
+	case *tweeters.Residual:
+		fmt.Println(prefix + "Residual")
+		printBlock(b.Block, indent+"  ", "", frozen)
+	case *anyrnn.LayerBlock:
+		if do, ok := b.Layer.(*anynet.Dropout); ok {
+			fmt.Printf("%sDropout (keep %v)\n", prefix, do.KeepProb)
+		} else {
+			printBlockLine(prefix, b.Layer, b, frozen)
+		}
+	default:
+		printBlockLine(prefix, block, block, frozen)
+	}
+}
+
+func printBlockLine(prefix string, obj interface{}, block anyrnn.Block, frozen bool) {
+	line := fmt.Sprintf("%s%T (%d params)", prefix, obj,
+		countParams(anynet.AllParameters(block)))
+	if frozen {
+		line += " [frozen]"
+	}
+	fmt.Println(line)
+}
+
+func describeFC(fc *anynet.FC) string {
+	return fmt.Sprintf("FC %dx%d (%d params)", fc.InCount, fc.OutCount,
+		countParams(fc.Parameters()))
+}
+
+func countParams(params []*anydiff.Var) int {
+	var res int
+	for _, p := range params {
+		res += p.Vector.Len()
+	}
+	return res
+}
+
+func numericType(model *tweeters.Model) string {
+	return fmt.Sprintf("%T", creator(model).MakeNumeric(0))
+}
+
+func creator(model *tweeters.Model) anyvec.Creator {
+	return model.Parameters()[0].Vector.Creator()
+}
+
+// freezeStack wraps (or unwraps) the blocks at the given
+// indices of an encoder stack in tweeters.Frozen.
+//
+// If indices is empty, every block except for dropout
+// layers is affected.
+func freezeStack(block anyrnn.Block, indices []int, frozen bool) (anyrnn.Block, error) {
+	stack, ok := block.(anyrnn.Stack)
+	if !ok {
+		return nil, errors.New("encoder is not a stack")
+	}
+	if len(indices) == 0 {
+		for i, sub := range stack {
+			if _, ok := sub.(*anyrnn.LayerBlock); !ok {
+				indices = append(indices, i)
+			}
+		}
+	}
+	res := append(anyrnn.Stack{}, stack...)
+	for _, i := range indices {
+		if i < 0 || i >= len(res) {
+			return nil, fmt.Errorf("encoder layer out of range: %d", i)
+		}
+		f, isFrozen := res[i].(*tweeters.Frozen)
+		if frozen && !isFrozen {
+			res[i] = &tweeters.Frozen{Block: res[i]}
+		} else if !frozen && isFrozen {
+			res[i] = f.Block
+		}
+	}
+	return res, nil
+}
+
+// classifierSizes finds the hidden layer sizes of a
+// model's classifier.
+func classifierSizes(model *tweeters.Model) ([]int, error) {
+	var res []int
+	for _, layer := range model.Classifier {
+		if fc, ok := layer.(*anynet.FC); ok {
+			res = append(res, fc.OutCount)
+		}
+	}
+	if len(res) == 0 {
+		return nil, errors.New("classifier has no FC layers")
+	}
+	return res[:len(res)-1], nil
+}
+
+// replaceClassifier gives a model a new, randomly
+// initialized classifier with the given hidden sizes.
+//
+// If features is non-empty, it replaces the model's
+// classifier features.
+func replaceClassifier(model *tweeters.Model, features string, hidden []int) error {
+	if len(model.Classifier) == 0 {
+		return errors.New("model has no classifier")
+	}
+	first, ok := model.Classifier[0].(*anynet.FC)
+	if !ok {
+		return fmt.Errorf("classifier starts with %T instead of an FC layer",
+			model.Classifier[0])
+	}
+	latentSize, err := latentSize(model.Features, first.InCount)
+	if err != nil {
+		return err
+	}
+	if features == "" {
+		features = model.Features
+	}
+	inSize, err := tweeters.FeatureSize(features, latentSize)
+	if err != nil {
+		return err
+	}
+	model.Features = features
+	model.Classifier = tweeters.NewClassifier(creator(model), inSize, hidden)
+	return nil
+}
+
+// convertModel converts every parameter of a model to use
+// the given creator.
+func convertModel(model *tweeters.Model, c anyvec.Creator) {
+	for _, p := range model.Parameters() {
+		var data []float64
+		switch vecData := p.Vector.Data().(type) {
+		case []float32:
+			for _, x := range vecData {
+				data = append(data, float64(x))
+			}
+		case []float64:
+			data = vecData
+		}
+		p.Vector = c.MakeVectorData(c.MakeNumericList(data))
+	}
+}
+
+// latentSize finds the latent vector size of a model from
+// the input size of its classifier.
+func latentSize(features string, classifierIn int) (int, error) {
+	switch features {
+	case "", tweeters.ConcatFeatures:
+		return classifierIn / 2, nil
+	case tweeters.SymmetricFeatures:
+		return (classifierIn - 1) / 2, nil
+	case tweeters.AllFeatures:
+		return (classifierIn - 1) / 4, nil
+	default:
+		return 0, errors.New("unknown feature mode: " + features)
+	}
+}
+
+func parseSizes(list string) ([]int, error) {
+	var res []int
+	for _, field := range strings.Split(list, ",") {
+		if field = strings.TrimSpace(field); field == "" {
+			continue
+		}
+		size, err := strconv.Atoi(field)
+		if err != nil {
+			return nil, errors.New("invalid integer: " + field)
+		}
+		res = append(res, size)
+	}
+	return res, nil
+}
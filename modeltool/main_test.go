@@ -0,0 +1,155 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/unixpickle/anynet"
+	"github.com/unixpickle/anynet/anyrnn"
+	"github.com/unixpickle/anyvec/anyvec32"
+	"github.com/unixpickle/anyvec/anyvec64"
+	"github.com/unixpickle/tweeters"
+)
+
+func TestFreezeStack(t *testing.T) {
+	c := anyvec64.CurrentCreator()
+	dropout := &anyrnn.LayerBlock{Layer: &anynet.Dropout{KeepProb: 0.5}}
+	stack := anyrnn.Stack{anyrnn.NewLSTM(c, 2, 3), dropout, anyrnn.NewLSTM(c, 3, 3)}
+
+	block, err := freezeStack(stack, nil, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	frozen := block.(anyrnn.Stack)
+	for _, i := range []int{0, 2} {
+		if f, ok := frozen[i].(*tweeters.Frozen); !ok || f.Block != stack[i] {
+			t.Errorf("layer %d should be frozen", i)
+		}
+	}
+	if frozen[1] != dropout {
+		t.Error("dropout layer should not be frozen")
+	}
+	if _, ok := stack[0].(*tweeters.Frozen); ok {
+		t.Error("original stack was modified")
+	}
+
+	block, err = freezeStack(frozen, []int{2}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unfrozen := block.(anyrnn.Stack)
+	if _, ok := unfrozen[0].(*tweeters.Frozen); !ok {
+		t.Error("layer 0 should still be frozen")
+	}
+	if unfrozen[2] != stack[2] {
+		t.Error("layer 2 should be unfrozen")
+	}
+
+	if _, err := freezeStack(stack, []int{3}, true); err == nil {
+		t.Error("expected error for out-of-range index")
+	}
+	if _, err := freezeStack(stack[0], nil, true); err == nil {
+		t.Error("expected error for non-stack encoder")
+	}
+}
+
+func TestLatentSize(t *testing.T) {
+	modes := []string{"", tweeters.ConcatFeatures, tweeters.SymmetricFeatures,
+		tweeters.AllFeatures}
+	for _, mode := range modes {
+		for _, size := range []int{1, 16, 511} {
+			inSize, err := tweeters.FeatureSize(mode, size)
+			if err != nil {
+				t.Fatal(err)
+			}
+			actual, err := latentSize(mode, inSize)
+			if err != nil {
+				t.Fatal(err)
+			} else if actual != size {
+				t.Errorf("mode %q: expected %d but got %d", mode, size, actual)
+			}
+		}
+	}
+	if _, err := latentSize("bogus", 10); err == nil {
+		t.Error("expected error for unknown mode")
+	}
+}
+
+func TestClassifierRoundTrip(t *testing.T) {
+	arch := tweeters.DefaultArchitecture()
+	arch.Layers = 1
+	arch.Hidden = 8
+	arch.Classifier = []int{6, 5}
+	model, err := arch.NewModel(anyvec64.CurrentCreator(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sizes, err := classifierSizes(model)
+	if err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(sizes, []int{6, 5}) {
+		t.Fatalf("unexpected sizes: %v", sizes)
+	}
+	if err := replaceClassifier(model, tweeters.AllFeatures, []int{4}); err != nil {
+		t.Fatal(err)
+	}
+	checkClassifier(t, model, tweeters.AllFeatures, 8*4+1, []int{4})
+
+	// Keeping the sizes and features should still work
+	// after a conversion, since the layers are re-created
+	// with the model's new creator.
+	convertModel(model, anyvec32.CurrentCreator())
+	sizes, err = classifierSizes(model)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := replaceClassifier(model, "", sizes); err != nil {
+		t.Fatal(err)
+	}
+	checkClassifier(t, model, tweeters.AllFeatures, 8*4+1, []int{4})
+	for _, p := range model.Parameters() {
+		if _, ok := p.Vector.Data().([]float32); !ok {
+			t.Fatalf("unexpected parameter type: %T", p.Vector.Data())
+		}
+	}
+
+	convertModel(model, anyvec64.CurrentCreator())
+	if err := replaceClassifier(model, tweeters.ConcatFeatures, nil); err != nil {
+		t.Fatal(err)
+	}
+	checkClassifier(t, model, tweeters.ConcatFeatures, 8*2, nil)
+	for _, p := range model.Parameters() {
+		if _, ok := p.Vector.Data().([]float64); !ok {
+			t.Fatalf("unexpected parameter type: %T", p.Vector.Data())
+		}
+	}
+}
+
+func TestReplaceClassifierErrors(t *testing.T) {
+	model := tweeters.NewModel(anyvec64.CurrentCreator(), nil, 0, 8, 1)
+	model.Classifier = anynet.Net{anynet.Tanh}
+	if _, err := classifierSizes(model); err == nil {
+		t.Error("expected error for classifier without FC layers")
+	}
+	if err := replaceClassifier(model, "", nil); err == nil {
+		t.Error("expected error for classifier without leading FC layer")
+	}
+}
+
+func checkClassifier(t *testing.T, model *tweeters.Model, features string, inSize int,
+	hidden []int) {
+	if model.Features != features {
+		t.Errorf("expected features %q but got %q", features, model.Features)
+	}
+	if actual := model.Classifier[0].(*anynet.FC).InCount; actual != inSize {
+		t.Errorf("expected input size %d but got %d", inSize, actual)
+	}
+	sizes, err := classifierSizes(model)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sizes) != len(hidden) || (len(hidden) > 0 && !reflect.DeepEqual(sizes, hidden)) {
+		t.Errorf("expected hidden sizes %v but got %v", hidden, sizes)
+	}
+}
@@ -0,0 +1,334 @@
+package tweeters
+
+import (
+	"errors"
+	"math"
+
+	"github.com/unixpickle/anydiff"
+	"github.com/unixpickle/anydiff/anyseq"
+	"github.com/unixpickle/anynet"
+	"github.com/unixpickle/anyvec"
+	"github.com/unixpickle/essentials"
+	"github.com/unixpickle/serializer"
+)
+
+func init() {
+	serializer.RegisterTypedDeserializer((&MeanPooler{}).SerializerType(),
+		DeserializeMeanPooler)
+	serializer.RegisterTypedDeserializer((&MaxPooler{}).SerializerType(),
+		DeserializeMaxPooler)
+	serializer.RegisterTypedDeserializer((&AttentionPooler{}).SerializerType(),
+		DeserializeAttentionPooler)
+}
+
+// A Pooler reduces the encoder's outputs at every
+// timestep to one latent vector per tweet.
+type Pooler interface {
+	serializer.Serializer
+
+	// Pool produces a packed list of n latent vectors from
+	// an output sequence with n tweets.
+	//
+	// Tweets without any timesteps are pooled to zero
+	// vectors.
+	// If no tweet has any timesteps, the output size is
+	// unknown, and the result is empty.
+	Pool(seq anyseq.Seq, n int) anydiff.Res
+}
+
+// NewPooler creates a Pooler by name.
+//
+// The name may be "mean", "max", or "attention".
+// The "tail" pooling strategy, which uses the final
+// timestep, is represented by a nil Pooler.
+// An empty name is equivalent to "tail".
+func NewPooler(c anyvec.Creator, name string, size int) (Pooler, error) {
+	switch name {
+	case "", "tail":
+		return nil, nil
+	case "mean":
+		return &MeanPooler{}, nil
+	case "max":
+		return &MaxPooler{}, nil
+	case "attention":
+		return NewAttentionPooler(c, size), nil
+	default:
+		return nil, errors.New("unknown pooling: " + name)
+	}
+}
+
+// MeanPooler averages the outputs over time.
+type MeanPooler struct{}
+
+// DeserializeMeanPooler deserializes a MeanPooler.
+func DeserializeMeanPooler(d []byte) (*MeanPooler, error) {
+	return &MeanPooler{}, nil
+}
+
+// Pool averages each tweet's outputs.
+func (m *MeanPooler) Pool(seq anyseq.Seq, n int) anydiff.Res {
+	flat := newFlatSeq(seq)
+	if flat.empty() {
+		return flat
+	}
+	c := seq.Creator()
+	counts := make([]float64, n)
+	for _, i := range flat.rowTweets {
+		counts[i]++
+	}
+	weights := make([]float64, len(flat.rowTweets))
+	for k, i := range flat.rowTweets {
+		weights[k] = 1 / counts[i]
+	}
+	weightVec := anydiff.NewConst(c.MakeVectorData(c.MakeNumericList(weights)))
+	return flat.sumTweets(flat.scaleRows(flat, weightVec), n)
+}
+
+// SerializerType returns the unique ID used to serialize
+// a MeanPooler with the serializer package.
+func (m *MeanPooler) SerializerType() string {
+	return "github.com/unixpickle/tweeters.MeanPooler"
+}
+
+// Serialize serializes the MeanPooler.
+func (m *MeanPooler) Serialize() ([]byte, error) {
+	return []byte{}, nil
+}
+
+// MaxPooler takes the maximum of each output component
+// over time.
+type MaxPooler struct{}
+
+// DeserializeMaxPooler deserializes a MaxPooler.
+func DeserializeMaxPooler(d []byte) (*MaxPooler, error) {
+	return &MaxPooler{}, nil
+}
+
+// Pool computes the componentwise maximum of each tweet's
+// outputs.
+func (m *MaxPooler) Pool(seq anyseq.Seq, n int) anydiff.Res {
+	flat := newFlatSeq(seq)
+	if flat.empty() {
+		return flat
+	}
+	c := seq.Creator()
+	data := VectorFloats(flat.Output())
+	cols := flat.cols()
+	table := make([]int, n*cols)
+	best := make([]float64, n*cols)
+	for i := range best {
+		best[i] = math.Inf(-1)
+	}
+	for k, i := range flat.rowTweets {
+		for j := 0; j < cols; j++ {
+			if x := data[k*cols+j]; x > best[i*cols+j] {
+				best[i*cols+j] = x
+				table[i*cols+j] = k*cols + j
+			}
+		}
+	}
+	res := anydiff.Map(c.MakeMapper(len(data), table), flat)
+
+	// Tweets without timesteps would otherwise take the
+	// first component of the first row.
+	mask := make([]float64, n*cols)
+	var masked bool
+	for i := range mask {
+		if math.IsInf(best[i], -1) {
+			masked = true
+		} else {
+			mask[i] = 1
+		}
+	}
+	if masked {
+		maskVec := anydiff.NewConst(c.MakeVectorData(c.MakeNumericList(mask)))
+		res = anydiff.Mul(res, maskVec)
+	}
+	return res
+}
+
+// SerializerType returns the unique ID used to serialize
+// a MaxPooler with the serializer package.
+func (m *MaxPooler) SerializerType() string {
+	return "github.com/unixpickle/tweeters.MaxPooler"
+}
+
+// Serialize serializes the MaxPooler.
+func (m *MaxPooler) Serialize() ([]byte, error) {
+	return []byte{}, nil
+}
+
+// AttentionPooler computes a weighted average of the
+// outputs over time.
+// The weights are a softmax of learned scores for each
+// timestep.
+type AttentionPooler struct {
+	// Scorer maps each output to a scalar score.
+	Scorer *anynet.FC
+}
+
+// NewAttentionPooler creates an AttentionPooler which
+// initially weights all timesteps equally.
+func NewAttentionPooler(c anyvec.Creator, size int) *AttentionPooler {
+	return &AttentionPooler{Scorer: anynet.NewFCZero(c, size, 1)}
+}
+
+// DeserializeAttentionPooler deserializes an
+// AttentionPooler.
+func DeserializeAttentionPooler(d []byte) (*AttentionPooler, error) {
+	var scorer *anynet.FC
+	if err := serializer.DeserializeAny(d, &scorer); err != nil {
+		return nil, essentials.AddCtx("deserialize AttentionPooler", err)
+	}
+	return &AttentionPooler{Scorer: scorer}, nil
+}
+
+// Pool computes the attention-weighted average of each
+// tweet's outputs.
+func (a *AttentionPooler) Pool(seq anyseq.Seq, n int) anydiff.Res {
+	flat := newFlatSeq(seq)
+	if flat.empty() {
+		return flat
+	}
+	numRows := len(flat.rowTweets)
+	return anydiff.Pool(flat, func(outs anydiff.Res) anydiff.Res {
+		scores := a.Scorer.Apply(outs, numRows)
+
+		// Subtracting a constant does not change the softmax,
+		// but it prevents overflow.
+		c := scores.Output().Creator()
+		maxScore := math.Inf(-1)
+		for _, x := range VectorFloats(scores.Output()) {
+			maxScore = math.Max(maxScore, x)
+		}
+		exps := anydiff.Exp(anydiff.AddScalar(scores, c.MakeNumeric(-maxScore)))
+
+		return anydiff.Pool(exps, func(exps anydiff.Res) anydiff.Res {
+			sums := flat.sumTweets(exps, n)
+			mapper := c.MakeMapper(n, flat.rowTweets)
+			weights := anydiff.Div(exps, anydiff.Map(mapper, sums))
+			return flat.sumTweets(flat.scaleRows(outs, weights), n)
+		})
+	})
+}
+
+// Parameters returns the scorer's parameters.
+func (a *AttentionPooler) Parameters() []*anydiff.Var {
+	return a.Scorer.Parameters()
+}
+
+// SerializerType returns the unique ID used to serialize
+// an AttentionPooler with the serializer package.
+func (a *AttentionPooler) SerializerType() string {
+	return "github.com/unixpickle/tweeters.AttentionPooler"
+}
+
+// Serialize serializes the AttentionPooler.
+func (a *AttentionPooler) Serialize() ([]byte, error) {
+	return serializer.SerializeAny(a.Scorer)
+}
+
+// flatSeq packs every timestep of a sequence into one
+// matrix, with one row per present entry.
+type flatSeq struct {
+	seq    anyseq.Seq
+	out    anyvec.Vector
+	counts []int
+
+	// rowTweets maps each row to its tweet index.
+	rowTweets []int
+
+	// rowTimes maps each row to its timestep.
+	rowTimes []int
+
+	// tweetRows lists the rows of each tweet, in order.
+	tweetRows [][]int
+}
+
+func newFlatSeq(seq anyseq.Seq) *flatSeq {
+	res := &flatSeq{seq: seq}
+	var vecs []anyvec.Vector
+	for t, batch := range seq.Output() {
+		vecs = append(vecs, batch.Packed)
+		if res.tweetRows == nil {
+			res.tweetRows = make([][]int, len(batch.Present))
+		}
+		var count int
+		for i, pres := range batch.Present {
+			if pres {
+				res.tweetRows[i] = append(res.tweetRows[i], len(res.rowTweets))
+				res.rowTweets = append(res.rowTweets, i)
+				res.rowTimes = append(res.rowTimes, t)
+				count++
+			}
+		}
+		res.counts = append(res.counts, count)
+	}
+	if len(vecs) == 0 {
+		res.out = seq.Creator().MakeVector(0)
+	} else {
+		res.out = seq.Creator().Concat(vecs...)
+	}
+	return res
+}
+
+func (f *flatSeq) Output() anyvec.Vector {
+	return f.out
+}
+
+func (f *flatSeq) Vars() anydiff.VarSet {
+	return f.seq.Vars()
+}
+
+func (f *flatSeq) Propagate(u anyvec.Vector, g anydiff.Grad) {
+	cols := f.cols()
+	var upstream []*anyseq.Batch
+	var offset int
+	for i, batch := range f.seq.Output() {
+		size := f.counts[i] * cols
+		upstream = append(upstream, &anyseq.Batch{
+			Present: batch.Present,
+			Packed:  u.Slice(offset, offset+size),
+		})
+		offset += size
+	}
+	f.seq.Propagate(upstream, g)
+}
+
+// empty checks if the sequence has no rows, in which case
+// the number of columns is unknown.
+func (f *flatSeq) empty() bool {
+	return len(f.rowTweets) == 0
+}
+
+// cols returns the number of columns, or 0 if the
+// sequence is empty.
+func (f *flatSeq) cols() int {
+	if f.empty() {
+		return 0
+	}
+	return f.out.Len() / len(f.rowTweets)
+}
+
+// scaleRows multiplies each row of a flattened matrix by
+// the corresponding entry of scales.
+func (f *flatSeq) scaleRows(rows, scales anydiff.Res) anydiff.Res {
+	return anydiff.Mul(rows, repeatCols(scales, len(f.rowTweets), f.cols()))
+}
+
+// sumTweets adds up the rows of a flattened matrix which
+// belong to each tweet.
+func (f *flatSeq) sumTweets(rows anydiff.Res, n int) anydiff.Res {
+	c := f.seq.Creator()
+	numRows := len(f.rowTweets)
+	cols := rows.Output().Len() / numRows
+	membership := make([]float64, n*numRows)
+	for k, i := range f.rowTweets {
+		membership[i*numRows+k] = 1
+	}
+	mat := anydiff.NewConst(c.MakeVectorData(c.MakeNumericList(membership)))
+	return anydiff.MatMul(false, false,
+		&anydiff.Matrix{Data: mat, Rows: n, Cols: numRows},
+		&anydiff.Matrix{Data: rows, Rows: numRows, Cols: cols},
+	).Data
+}
@@ -0,0 +1,86 @@
+package tweeters
+
+import (
+	"math"
+	"testing"
+
+	"github.com/unixpickle/anydiff/anyseq"
+	"github.com/unixpickle/anyvec/anyvec64"
+)
+
+func TestPoolers(t *testing.T) {
+	c := anyvec64.CurrentCreator()
+	seq := anyseq.ConstSeq(c, []*anyseq.Batch{
+		{Present: []bool{true, true}, Packed: c.MakeVectorData([]float64{1, 2, 3, -4})},
+		{Present: []bool{true, false}, Packed: c.MakeVectorData([]float64{5, -6})},
+		{Present: []bool{true, false}, Packed: c.MakeVectorData([]float64{0, 1})},
+	})
+	mean := []float64{2, -1, 3, -4}
+	for _, test := range []struct {
+		Pooler   Pooler
+		Expected []float64
+	}{
+		{&MeanPooler{}, mean},
+		{&MaxPooler{}, []float64{5, 2, 3, -4}},
+		{NewAttentionPooler(c, 2), mean},
+	} {
+		actual := VectorFloats(test.Pooler.Pool(seq, 2).Output())
+		for i, x := range test.Expected {
+			if math.Abs(actual[i]-x) > 1e-8 {
+				t.Errorf("%T: expected %v but got %v", test.Pooler, test.Expected, actual)
+				break
+			}
+		}
+	}
+}
+
+func TestPoolersEmpty(t *testing.T) {
+	c := anyvec64.CurrentCreator()
+	seq := anyseq.ConstSeq(c, []*anyseq.Batch{
+		{Present: []bool{true, false}, Packed: c.MakeVectorData([]float64{1, 2})},
+		{Present: []bool{true, false}, Packed: c.MakeVectorData([]float64{3, -4})},
+	})
+	mean := []float64{2, -1, 0, 0}
+	for _, test := range []struct {
+		Pooler   Pooler
+		Expected []float64
+	}{
+		{&MeanPooler{}, mean},
+		{&MaxPooler{}, []float64{3, 2, 0, 0}},
+		{NewAttentionPooler(c, 2), mean},
+	} {
+		actual := VectorFloats(test.Pooler.Pool(seq, 2).Output())
+		if len(actual) != len(test.Expected) {
+			t.Errorf("%T: expected %d outputs but got %d", test.Pooler,
+				len(test.Expected), len(actual))
+			continue
+		}
+		for i, x := range test.Expected {
+			if math.Abs(actual[i]-x) > 1e-8 {
+				t.Errorf("%T: expected %v but got %v", test.Pooler, test.Expected, actual)
+				break
+			}
+		}
+		if out := test.Pooler.Pool(anyseq.ConstSeq(c, nil), 2).Output(); out.Len() != 0 {
+			t.Errorf("%T: unexpected output for empty sequence: %v", test.Pooler,
+				VectorFloats(out))
+		}
+	}
+}
+
+func TestPoolingModel(t *testing.T) {
+	c := anyvec64.CurrentCreator()
+	for _, pooling := range []string{"mean", "max", "attention"} {
+		arch := &Architecture{Cell: "lstm", Layers: 1, Hidden: 8, Pooling: pooling,
+			Bidirectional: true, Dropout: 1}
+		model, err := arch.NewModel(c, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tweets := randomTweets(3)
+		if size := model.Encode(tweets).Output().Len(); size != 3*16 {
+			t.Fatalf("%s: unexpected latent size %d", pooling, size)
+		}
+		checkRoundTrip(t, model, tweets)
+	}
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"math/rand"
+	"time"
+
+	"github.com/unixpickle/anydiff"
+	"github.com/unixpickle/anynet/anysgd"
+	"github.com/unixpickle/anyvec"
+	"github.com/unixpickle/anyvec/anyvec32"
+	"github.com/unixpickle/essentials"
+	"github.com/unixpickle/rip"
+	"github.com/unixpickle/serializer"
+	"github.com/unixpickle/tweeters"
+)
+
+func main() {
+	rand.Seed(time.Now().UnixNano())
+
+	var trainer Trainer
+	var sgd anysgd.SGD
+	var modelPath string
+	var samplesPath string
+	var stepSize float64
+	var validation float64
+	var tokenizer string
+	var vocab int
+	var bpeSamples int
+	var archPath string
+	arch := tweeters.DefaultArchitecture()
+
+	flag.StringVar(&modelPath, "out", "model_out", "path to model file")
+	flag.StringVar(&samplesPath, "data", "", "path to tweet database")
+	flag.IntVar(&sgd.BatchSize, "batch", 64, "batch size (in tweets)")
+	flag.Float64Var(&stepSize, "step", 0.001, "SGD step size")
+	flag.Float64Var(&validation, "validation", 0.1, "validation fraction")
+	flag.StringVar(&archPath, "arch", "",
+		"JSON architecture for new networks (overrides architecture flags)")
+	flag.StringVar(&arch.Cell, "cell", arch.Cell, "cell type (lstm, gru, or vanilla)")
+	flag.IntVar(&arch.Layers, "layers", arch.Layers, "number of recurrent layers")
+	flag.IntVar(&arch.Hidden, "hidden", arch.Hidden, "state size for new networks")
+	flag.IntVar(&arch.EmbedDim, "embed", 0, "embedding size for new networks (0 for one-hot)")
+	flag.Float64Var(&arch.Dropout, "dropout", arch.Dropout, "dropout keep probability")
+	flag.StringVar(&tokenizer, "tokenizer", "bytes",
+		"tokenizer for new networks (bytes, codepoints, or bpe)")
+	flag.IntVar(&vocab, "vocab", 1024, "vocabulary size for codepoints or bpe tokenizers")
+	flag.IntVar(&bpeSamples, "bpesamples", 5000, "tweets used to train bpe tokenizers")
+	flag.IntVar(&arch.MaxLen, "maxlen", 0, "maximum tweet length in bytes for new networks")
+	flag.StringVar(&arch.Truncation, "truncation", arch.Truncation,
+		"truncation strategy for -maxlen (head, tail, or middle)")
+	flag.Parse()
+
+	if samplesPath == "" {
+		essentials.Die("Required flag: -data. See -help.")
+	}
+
+	log.Println("Loading samples...")
+	db, err := tweeters.OpenDB(samplesPath)
+	if err != nil {
+		essentials.Die(err)
+	}
+	samples := tweeters.NewSamples(db)
+
+	// Use the same partition as train, so that validation
+	// users are never seen during pretraining.
+	training, testing := samples.Partition(validation)
+
+	if err := serializer.LoadAny(modelPath, &trainer.Model); err == nil {
+		log.Println("Loaded model.")
+	} else {
+		log.Println("Creating new model...")
+		if archPath != "" {
+			arch, err = tweeters.LoadArchitecture(archPath)
+			if err != nil {
+				essentials.Die(err)
+			}
+		}
+		arch.LMHead = true
+		var corpus [][]byte
+		if tokenizer == "bpe" {
+			log.Println("Training BPE tokenizer...")
+			corpus, err = training.RandomTweets(bpeSamples)
+			if err != nil {
+				essentials.Die(err)
+			}
+		}
+		tok, err := tweeters.NewTokenizer(tokenizer, vocab, corpus)
+		if err != nil {
+			essentials.Die(err)
+		}
+		trainer.Model, err = arch.NewModel(anyvec32.CurrentCreator(), tok)
+		if err != nil {
+			essentials.Die(err)
+		}
+	}
+	if trainer.Model.LMHead == nil {
+		essentials.Die("Model has no language model head.")
+	}
+	trainer.Model.SetDropout(true)
+	training.Truncation = trainer.Model.Truncation
+	testing.Truncation = trainer.Model.Truncation
+
+	trainer.Samples = training
+
+	sgd.Rater = anysgd.ConstRater(stepSize)
+	sgd.Transformer = &anysgd.Adam{}
+	sgd.Fetcher = &trainer
+	sgd.Gradienter = &trainer
+	sgd.Samples = anysgd.LengthSampleList(sgd.BatchSize)
+
+	var iter int
+	sgd.StatusFunc = func(b anysgd.Batch) {
+		if iter%4 == 0 {
+			validator := trainer
+			validator.Samples = testing
+			batch, err := validator.Fetch(sgd.Samples)
+			if err != nil {
+				essentials.Die(err)
+			}
+			validator.Model.SetDropout(false)
+			cost := anyvec.Sum(validator.Model.LanguageModelCost(batch.(*Batch).Tweets).Output())
+			validator.Model.SetDropout(true)
+			log.Printf("iter %d: cost=%v validation=%v", iter, trainer.LastCost, cost)
+		} else {
+			log.Printf("iter %d: cost=%v", iter, trainer.LastCost)
+		}
+		iter++
+	}
+
+	log.Println("Pretraining (ctrl+c to finish)...")
+	sgd.Run(rip.NewRIP().Chan())
+
+	log.Println("Saving model...")
+	trainer.Model.SetDropout(false)
+	if err := serializer.SaveAny(modelPath, trainer.Model); err != nil {
+		essentials.Die(err)
+	}
+}
+
+// A Trainer fetches batches of tweets and computes
+// language modeling gradients for the encoder.
+type Trainer struct {
+	Model   *tweeters.Model
+	Samples *tweeters.Samples
+
+	// Set by Gradient().
+	LastCost anyvec.Numeric
+}
+
+// Fetch produces a batch of random tweets, with one tweet
+// per entry of s.
+func (t *Trainer) Fetch(s anysgd.SampleList) (anysgd.Batch, error) {
+	tweets, err := t.Samples.RandomTweets(s.Len())
+	if err != nil {
+		return nil, err
+	}
+	return &Batch{Tweets: tweets}, nil
+}
+
+// Gradient computes the gradient of the language modeling
+// cost.
+//
+// The classifier is not involved, so its gradient is
+// always zero.
+func (t *Trainer) Gradient(batch anysgd.Batch) anydiff.Grad {
+	grad := anydiff.NewGrad(t.Model.TrainableParameters()...)
+
+	cost := t.Model.LanguageModelCost(batch.(*Batch).Tweets)
+	t.LastCost = anyvec.Sum(cost.Output())
+
+	c := cost.Output().Creator()
+	one := c.MakeVector(1)
+	one.AddScalar(c.MakeNumeric(1))
+	cost.Propagate(one, grad)
+
+	return grad
+}
+
+// A Batch is a list of tweets.
+type Batch struct {
+	Tweets [][]byte
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/unixpickle/essentials"
+)
+
+// A Dataset is a list of labeled texts.
+type Dataset struct {
+	Texts  [][]byte
+	Labels []int
+
+	// LabelNames maps label indices to the label strings
+	// from the data file.
+	LabelNames []string
+}
+
+// ReadDataset reads a labeled dataset.
+//
+// Files ending in .jsonl or .json are read as JSON lines
+// with "text" and "label" fields.
+// All other files are read as CSV files with a text
+// column followed by a label column.
+// An optional "text,label" header row is skipped.
+func ReadDataset(path string) (data *Dataset, err error) {
+	defer essentials.AddCtxTo("read dataset", &err)
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	data = &Dataset{}
+	labelIndices := map[string]int{}
+	add := func(text, label string) {
+		if _, ok := labelIndices[label]; !ok {
+			labelIndices[label] = len(data.LabelNames)
+			data.LabelNames = append(data.LabelNames, label)
+		}
+		data.Texts = append(data.Texts, []byte(text))
+		data.Labels = append(data.Labels, labelIndices[label])
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".jsonl", ".json":
+		err = readJSONLines(f, add)
+	default:
+		err = readCSV(f, add)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(data.LabelNames) < 2 {
+		return nil, errors.New("need at least two distinct labels")
+	}
+	return data, nil
+}
+
+// NumClasses returns the number of distinct labels.
+func (d *Dataset) NumClasses() int {
+	return len(d.LabelNames)
+}
+
+func readJSONLines(r io.Reader, add func(text, label string)) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		var obj struct {
+			Text  string      `json:"text"`
+			Label interface{} `json:"label"`
+		}
+		if err := json.Unmarshal([]byte(line), &obj); err != nil {
+			return err
+		}
+		if obj.Label == nil {
+			return errors.New("missing label field")
+		}
+		add(obj.Text, fmt.Sprint(obj.Label))
+	}
+	return scanner.Err()
+}
+
+func readCSV(r io.Reader, add func(text, label string)) error {
+	reader := csv.NewReader(r)
+	first := true
+	for {
+		row, err := reader.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+		if len(row) < 2 {
+			return errors.New("expected at least 2 columns")
+		}
+		if first && row[0] == "text" && row[1] == "label" {
+			first = false
+			continue
+		}
+		first = false
+		add(row[0], row[1])
+	}
+	return nil
+}
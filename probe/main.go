@@ -0,0 +1,95 @@
+// Command probe trains linear classifiers on top of a
+// frozen encoder to see how useful its latent features
+// are for downstream tasks such as sentiment analysis.
+//
+// Accuracy is reported using k-fold cross-validation,
+// alongside a bag-of-characters baseline trained on the
+// same folds.
+package main
+
+import (
+	"flag"
+	"log"
+
+	"github.com/unixpickle/essentials"
+	"github.com/unixpickle/serializer"
+	"github.com/unixpickle/tweeters"
+)
+
+func main() {
+	var modelPath string
+	var dataPath string
+	var batchSize int
+	var folds int
+	var trainer SoftmaxTrainer
+	flag.StringVar(&modelPath, "model", "../train/model_out", "path to trained model")
+	flag.StringVar(&dataPath, "data", "", "path to labeled CSV or JSONL file")
+	flag.IntVar(&batchSize, "batch", 64, "encoder batch size")
+	flag.IntVar(&folds, "folds", 5, "number of cross-validation folds")
+	flag.IntVar(&trainer.Iters, "iters", 500, "gradient descent iterations")
+	flag.Float64Var(&trainer.StepSize, "step", 0.1, "gradient descent step size")
+	flag.Float64Var(&trainer.L2, "l2", 1e-3, "L2 regularization coefficient")
+	flag.Parse()
+
+	if dataPath == "" {
+		essentials.Die("Required flag: -data. See -help.")
+	}
+
+	log.Println("Loading model...")
+	var model *tweeters.Model
+	if err := serializer.LoadAny(modelPath, &model); err != nil {
+		essentials.Die(err)
+	}
+	model.SetDropout(false)
+
+	log.Println("Loading dataset...")
+	data, err := ReadDataset(dataPath)
+	if err != nil {
+		essentials.Die(err)
+	}
+	if len(data.Texts) < folds {
+		essentials.Die("not enough samples for", folds, "folds")
+	}
+	log.Printf("%d samples, %d classes", len(data.Texts), data.NumClasses())
+
+	log.Println("Embedding texts...")
+	latent := EmbedTexts(model, data.Texts, batchSize)
+
+	log.Println("Training latent probe...")
+	latentAcc := CrossValidate(&trainer, latent, data.Labels, data.NumClasses(), folds)
+	log.Println("Training bag-of-characters baseline...")
+	baselineAcc := CrossValidate(&trainer, BagOfChars(data.Texts), data.Labels,
+		data.NumClasses(), folds)
+
+	log.Printf("latent probe: %.2f%%", 100*latentAcc)
+	log.Printf("bag-of-characters: %.2f%%", 100*baselineAcc)
+}
+
+// EmbedTexts encodes the texts with the model, batchSize
+// texts at a time.
+func EmbedTexts(model *tweeters.Model, texts [][]byte, batchSize int) [][]float64 {
+	var res [][]float64
+	for i := 0; i < len(texts); i += batchSize {
+		batch := texts[i:essentials.MinInt(len(texts), i+batchSize)]
+		data := tweeters.VectorFloats(model.Encode(batch).Output())
+		latentSize := len(data) / len(batch)
+		for j := range batch {
+			vec := make([]float64, latentSize)
+			copy(vec, data[j*latentSize:(j+1)*latentSize])
+			res = append(res, vec)
+		}
+	}
+	return res
+}
+
+// BagOfChars computes normalized byte histograms.
+func BagOfChars(texts [][]byte) [][]float64 {
+	res := make([][]float64, len(texts))
+	for i, text := range texts {
+		res[i] = make([]float64, 0x100)
+		for _, b := range text {
+			res[i][b] += 1 / float64(len(text))
+		}
+	}
+	return res
+}
@@ -0,0 +1,46 @@
+package main
+
+import "testing"
+
+func TestReadDataset(t *testing.T) {
+	data, err := ReadDataset("testdata/tiny.jsonl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedNames := []string{"positive", "negative", "3"}
+	expectedLabels := []int{0, 1, 2}
+	if len(data.Texts) != 3 || data.NumClasses() != 3 {
+		t.Fatalf("unexpected sizes: %d texts, %d classes", len(data.Texts), data.NumClasses())
+	}
+	for i, name := range expectedNames {
+		if data.LabelNames[i] != name {
+			t.Errorf("label %d: expected %s but got %s", i, name, data.LabelNames[i])
+		}
+		if data.Labels[i] != expectedLabels[i] {
+			t.Errorf("sample %d: expected label %d but got %d", i, expectedLabels[i],
+				data.Labels[i])
+		}
+	}
+	if string(data.Texts[1]) != "I hate this so much." {
+		t.Errorf("unexpected text: %s", data.Texts[1])
+	}
+}
+
+func TestBagOfCharsProbe(t *testing.T) {
+	data, err := ReadDataset("testdata/tiny.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data.Texts) != 16 || data.NumClasses() != 2 {
+		t.Fatalf("unexpected sizes: %d texts, %d classes", len(data.Texts), data.NumClasses())
+	}
+	trainer := &SoftmaxTrainer{Iters: 200, StepSize: 0.1, L2: 1e-3}
+	features := BagOfChars(data.Texts)
+	model := trainer.Train(features, data.Labels, data.NumClasses())
+	if acc := model.Accuracy(features, data.Labels); acc < 0.9 {
+		t.Errorf("training accuracy too low: %f", acc)
+	}
+	if acc := CrossValidate(trainer, features, data.Labels, data.NumClasses(), 4); acc < 0.5 {
+		t.Errorf("cross-validated accuracy too low: %f", acc)
+	}
+}
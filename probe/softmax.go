@@ -0,0 +1,185 @@
+package main
+
+import (
+	"math"
+	"math/rand"
+)
+
+// A Softmax is a multinomial logistic regression model on
+// top of standardized features.
+type Softmax struct {
+	Mean  []float64
+	Scale []float64
+
+	// Weights stores one row per class.
+	// The last entry in each row is a bias.
+	Weights [][]float64
+}
+
+// SoftmaxTrainer trains Softmax models with full-batch
+// gradient descent.
+type SoftmaxTrainer struct {
+	Iters    int
+	StepSize float64
+	L2       float64
+}
+
+// Train fits a Softmax model to the samples.
+func (s *SoftmaxTrainer) Train(features [][]float64, labels []int, numClasses int) *Softmax {
+	model := newStandardizedSoftmax(features, numClasses)
+	inputs := make([][]float64, len(features))
+	for i, f := range features {
+		inputs[i] = model.standardize(f)
+	}
+	numFeatures := len(model.Mean)
+	for iter := 0; iter < s.Iters; iter++ {
+		grad := make([][]float64, numClasses)
+		for i := range grad {
+			grad[i] = make([]float64, numFeatures+1)
+		}
+		for i, in := range inputs {
+			probs := model.probs(in)
+			for class, prob := range probs {
+				delta := prob
+				if class == labels[i] {
+					delta--
+				}
+				row := grad[class]
+				for j, x := range in {
+					row[j] += delta * x
+				}
+				row[numFeatures] += delta
+			}
+		}
+		scale := s.StepSize / float64(len(inputs))
+		for class, row := range model.Weights {
+			for j := range row {
+				g := grad[class][j]
+				if j < numFeatures {
+					g += s.L2 * float64(len(inputs)) * row[j]
+				}
+				row[j] -= scale * g
+			}
+		}
+	}
+	return model
+}
+
+// Predict returns the most likely class for the features.
+func (s *Softmax) Predict(features []float64) int {
+	var best int
+	var bestLogit float64
+	for class, logit := range s.logits(s.standardize(features)) {
+		if class == 0 || logit > bestLogit {
+			best = class
+			bestLogit = logit
+		}
+	}
+	return best
+}
+
+// Accuracy computes the fraction of correct predictions.
+func (s *Softmax) Accuracy(features [][]float64, labels []int) float64 {
+	var correct int
+	for i, f := range features {
+		if s.Predict(f) == labels[i] {
+			correct++
+		}
+	}
+	return float64(correct) / float64(len(features))
+}
+
+func newStandardizedSoftmax(features [][]float64, numClasses int) *Softmax {
+	numFeatures := len(features[0])
+	res := &Softmax{
+		Mean:    make([]float64, numFeatures),
+		Scale:   make([]float64, numFeatures),
+		Weights: make([][]float64, numClasses),
+	}
+	for i := range res.Weights {
+		res.Weights[i] = make([]float64, numFeatures+1)
+	}
+	for _, f := range features {
+		for j, x := range f {
+			res.Mean[j] += x / float64(len(features))
+		}
+	}
+	for _, f := range features {
+		for j, x := range f {
+			res.Scale[j] += math.Pow(x-res.Mean[j], 2) / float64(len(features))
+		}
+	}
+	for j, variance := range res.Scale {
+		if variance == 0 {
+			res.Scale[j] = 1
+		} else {
+			res.Scale[j] = 1 / math.Sqrt(variance)
+		}
+	}
+	return res
+}
+
+func (s *Softmax) standardize(features []float64) []float64 {
+	res := make([]float64, len(features))
+	for i, x := range features {
+		res[i] = (x - s.Mean[i]) * s.Scale[i]
+	}
+	return res
+}
+
+func (s *Softmax) logits(in []float64) []float64 {
+	res := make([]float64, len(s.Weights))
+	for class, row := range s.Weights {
+		sum := row[len(in)]
+		for j, x := range in {
+			sum += row[j] * x
+		}
+		res[class] = sum
+	}
+	return res
+}
+
+func (s *Softmax) probs(in []float64) []float64 {
+	logits := s.logits(in)
+	max := logits[0]
+	for _, x := range logits {
+		max = math.Max(max, x)
+	}
+	var sum float64
+	for i, x := range logits {
+		logits[i] = math.Exp(x - max)
+		sum += logits[i]
+	}
+	for i := range logits {
+		logits[i] /= sum
+	}
+	return logits
+}
+
+// CrossValidate computes the mean held-out accuracy of
+// the trainer over k folds.
+//
+// The folds are chosen with a fixed random seed, so that
+// different feature sets can be compared on the same
+// splits.
+func CrossValidate(t *SoftmaxTrainer, features [][]float64, labels []int, numClasses,
+	k int) float64 {
+	perm := rand.New(rand.NewSource(1337)).Perm(len(features))
+	var total float64
+	for fold := 0; fold < k; fold++ {
+		var trainF, testF [][]float64
+		var trainL, testL []int
+		for i, j := range perm {
+			if i%k == fold {
+				testF = append(testF, features[j])
+				testL = append(testL, labels[j])
+			} else {
+				trainF = append(trainF, features[j])
+				trainL = append(trainL, labels[j])
+			}
+		}
+		model := t.Train(trainF, trainL, numClasses)
+		total += model.Accuracy(testF, testL)
+	}
+	return total / float64(k)
+}
@@ -0,0 +1,174 @@
+// Command project exports a 2D projection of tweet or
+// user vectors as an interactive HTML scatter plot.
+//
+// Tweet vectors come from Model.Encode, while user
+// vectors are averages from Model.Averages.
+// The vectors can be projected with PCA or t-SNE.
+package main
+
+import (
+	"flag"
+	"log"
+	"math/rand"
+	"os"
+	"time"
+
+	"github.com/unixpickle/anyvec"
+	"github.com/unixpickle/essentials"
+	"github.com/unixpickle/serializer"
+	"github.com/unixpickle/tweeters"
+)
+
+func main() {
+	rand.Seed(time.Now().UnixNano())
+
+	var modelPath string
+	var dbPath string
+	var outPath string
+	var kind string
+	var method string
+	var numPoints int
+	var maxTweets int
+	var batchSize int
+	tsne := TSNE{Dims: 2}
+	flag.StringVar(&modelPath, "model", "../train/model_out", "path to trained model")
+	flag.StringVar(&dbPath, "data", "", "path to tweet DB")
+	flag.StringVar(&outPath, "out", "projection.html", "output HTML file")
+	flag.StringVar(&kind, "kind", "tweets", "vectors to plot (tweets or users)")
+	flag.StringVar(&method, "method", "pca", "projection method (pca or tsne)")
+	flag.IntVar(&numPoints, "n", 1000, "number of points to plot")
+	flag.IntVar(&maxTweets, "max", 16, "maximum tweets per user (users only)")
+	flag.IntVar(&batchSize, "batch", 256, "maximum tweets per encoder batch")
+	flag.Float64Var(&tsne.Perplexity, "perplexity", 30, "t-SNE perplexity")
+	flag.IntVar(&tsne.Iters, "iters", 500, "t-SNE iterations")
+	flag.Float64Var(&tsne.StepSize, "step", 0, "t-SNE step size (0 for automatic)")
+	flag.Parse()
+
+	if dbPath == "" {
+		essentials.Die("Required flag: -data. See -help.")
+	}
+	if kind != "tweets" && kind != "users" {
+		essentials.Die("unknown kind:", kind)
+	}
+	if method != "pca" && method != "tsne" {
+		essentials.Die("unknown method:", method)
+	}
+
+	log.Println("Loading model...")
+	var model *tweeters.Model
+	if err := serializer.LoadAny(modelPath, &model); err != nil {
+		essentials.Die(err)
+	}
+	model.SetDropout(false)
+
+	log.Println("Loading DB...")
+	db, err := tweeters.OpenDB(dbPath)
+	if err != nil {
+		essentials.Die(err)
+	}
+	defer db.Close()
+
+	log.Printf("Embedding %s...", kind)
+	var vecs [][]float64
+	var labels []string
+	if kind == "tweets" {
+		vecs, labels, err = embedTweets(model, db, numPoints, batchSize)
+	} else {
+		vecs, labels, err = embedUsers(model, db, numPoints, maxTweets, batchSize)
+	}
+	if err != nil {
+		essentials.Die(err)
+	}
+	if len(vecs) < 2 {
+		essentials.Die("not enough points to plot")
+	}
+
+	log.Printf("Projecting %d points...", len(vecs))
+	var coords [][]float64
+	if method == "pca" {
+		coords = PCA(vecs, 2)
+	} else {
+		coords = tsne.Embed(vecs)
+	}
+
+	log.Println("Writing plot...")
+	f, err := os.Create(outPath)
+	if err != nil {
+		essentials.Die(err)
+	}
+	defer f.Close()
+	title := "Latent " + kind + " (" + method + ")"
+	if err := WritePlot(f, title, coords, labels); err != nil {
+		essentials.Die(err)
+	}
+}
+
+func embedTweets(model *tweeters.Model, db *tweeters.DB, n,
+	batchSize int) ([][]float64, []string, error) {
+	var tweets [][]byte
+	var labels []string
+	for _, idx := range rand.Perm(db.NumUsers()) {
+		if len(tweets) == n {
+			break
+		}
+		records, err := db.Read(idx)
+		if err != nil {
+			return nil, nil, err
+		}
+		record := records[rand.Intn(len(records))]
+		tweets = append(tweets, record.Body)
+		labels = append(labels, "@"+string(record.User)+": "+string(record.Body))
+	}
+	var vecs [][]float64
+	for i := 0; i < len(tweets); i += batchSize {
+		batch := tweets[i:essentials.MinInt(len(tweets), i+batchSize)]
+		vecs = append(vecs, splitFloats(model.Encode(batch).Output(), len(batch))...)
+	}
+	return vecs, labels, nil
+}
+
+func embedUsers(model *tweeters.Model, db *tweeters.DB, n, maxTweets,
+	batchSize int) ([][]float64, []string, error) {
+	var vecs [][]float64
+	var labels []string
+	var tweets [][]byte
+	var sizes []int
+	flush := func() {
+		if len(sizes) > 0 {
+			out := model.Averages(tweets, sizes).Output()
+			vecs = append(vecs, splitFloats(out, len(sizes))...)
+			tweets, sizes = nil, nil
+		}
+	}
+	for _, idx := range rand.Perm(db.NumUsers()) {
+		if len(labels) == n {
+			break
+		}
+		records, err := db.Read(idx)
+		if err != nil {
+			return nil, nil, err
+		}
+		numTweets := essentials.MinInt(len(records), maxTweets)
+		if len(tweets)+numTweets > batchSize {
+			flush()
+		}
+		for _, i := range rand.Perm(len(records))[:numTweets] {
+			tweets = append(tweets, records[i].Body)
+		}
+		sizes = append(sizes, numTweets)
+		labels = append(labels, "@"+string(records[0].User))
+	}
+	flush()
+	return vecs, labels, nil
+}
+
+func splitFloats(v anyvec.Vector, n int) [][]float64 {
+	data := tweeters.VectorFloats(v)
+	size := len(data) / n
+	res := make([][]float64, n)
+	for i := range res {
+		res[i] = make([]float64, size)
+		copy(res[i], data[i*size:(i+1)*size])
+	}
+	return res
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"math"
+	"math/rand"
+)
+
+// PCA projects vectors onto their top principal
+// components.
+//
+// The components are found with power iteration on the
+// covariance matrix, deflating after each component.
+func PCA(vecs [][]float64, dims int) [][]float64 {
+	size := len(vecs[0])
+	mean := make([]float64, size)
+	for _, v := range vecs {
+		for i, x := range v {
+			mean[i] += x / float64(len(vecs))
+		}
+	}
+	centered := make([][]float64, len(vecs))
+	for i, v := range vecs {
+		centered[i] = make([]float64, size)
+		for j, x := range v {
+			centered[i][j] = x - mean[j]
+		}
+	}
+
+	var components [][]float64
+	for d := 0; d < dims; d++ {
+		components = append(components, principalComponent(centered, components))
+	}
+
+	res := make([][]float64, len(vecs))
+	for i, v := range centered {
+		res[i] = make([]float64, dims)
+		for d, comp := range components {
+			res[i][d] = dot(v, comp)
+		}
+	}
+	return res
+}
+
+func principalComponent(centered, prev [][]float64) []float64 {
+	vec := make([]float64, len(centered[0]))
+	for i := range vec {
+		vec[i] = rand.NormFloat64()
+	}
+	for iter := 0; iter < 100; iter++ {
+		// Multiply by the (unnormalized) covariance matrix.
+		next := make([]float64, len(vec))
+		for _, v := range centered {
+			proj := dot(v, vec)
+			for i, x := range v {
+				next[i] += proj * x
+			}
+		}
+		for _, p := range prev {
+			proj := dot(next, p)
+			for i, x := range p {
+				next[i] -= proj * x
+			}
+		}
+		norm := math.Sqrt(dot(next, next))
+		if norm == 0 {
+			break
+		}
+		for i := range next {
+			next[i] /= norm
+		}
+		vec = next
+	}
+	return vec
+}
+
+func dot(v1, v2 []float64) float64 {
+	var res float64
+	for i, x := range v1 {
+		res += x * v2[i]
+	}
+	return res
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"html/template"
+	"io"
+	"math"
+)
+
+const (
+	plotSize    = 800
+	plotPadding = 20
+)
+
+var plotTemplate = template.Must(template.New("plot").Parse(`<!doctype html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: sans-serif; }
+circle { fill: #1f77b4; fill-opacity: 0.6; }
+circle:hover { fill: #d62728; fill-opacity: 1; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<p>Hover over a point to see its label.</p>
+<svg width="{{.Size}}" height="{{.Size}}" style="border: 1px solid #ccc">
+{{- range .Points}}
+<circle cx="{{printf "%.2f" .X}}" cy="{{printf "%.2f" .Y}}" r="4"><title>{{.Label}}</title></circle>
+{{- end}}
+</svg>
+</body>
+</html>
+`))
+
+// A PlotPoint is a labeled point in a scatter plot.
+type PlotPoint struct {
+	X     float64
+	Y     float64
+	Label string
+}
+
+// WritePlot writes a self-contained HTML scatter plot.
+//
+// The coordinates are rescaled to fit the plot, and each
+// point shows its label when hovered over.
+func WritePlot(w io.Writer, title string, coords [][]float64, labels []string) error {
+	minX, maxX := math.Inf(1), math.Inf(-1)
+	minY, maxY := math.Inf(1), math.Inf(-1)
+	for _, c := range coords {
+		minX, maxX = math.Min(minX, c[0]), math.Max(maxX, c[0])
+		minY, maxY = math.Min(minY, c[1]), math.Max(maxY, c[1])
+	}
+	scale := func(x, min, max float64) float64 {
+		if max == min {
+			return plotSize / 2
+		}
+		return plotPadding + (plotSize-2*plotPadding)*(x-min)/(max-min)
+	}
+	points := make([]PlotPoint, len(coords))
+	for i, c := range coords {
+		points[i] = PlotPoint{
+			X:     scale(c[0], minX, maxX),
+			Y:     plotSize - scale(c[1], minY, maxY),
+			Label: labels[i],
+		}
+	}
+	return plotTemplate.Execute(w, map[string]interface{}{
+		"Title":  title,
+		"Size":   plotSize,
+		"Points": points,
+	})
+}
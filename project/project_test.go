@@ -0,0 +1,69 @@
+package main
+
+import (
+	"bytes"
+	"math"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func TestPCA(t *testing.T) {
+	// Points along the line y = 2x, offset in z.
+	var vecs [][]float64
+	for i := 0; i < 10; i++ {
+		x := float64(i)
+		vecs = append(vecs, []float64{x, 2 * x, 3})
+	}
+	coords := PCA(vecs, 2)
+	for i, c := range coords {
+		expected := (float64(i) - 4.5) * math.Sqrt(5)
+		if math.Abs(math.Abs(c[0])-math.Abs(expected)) > 1e-5 {
+			t.Errorf("point %d: expected first coordinate ±%f but got %f", i, expected, c[0])
+		}
+		if math.Abs(c[1]) > 1e-5 {
+			t.Errorf("point %d: expected zero second coordinate but got %f", i, c[1])
+		}
+	}
+}
+
+func TestTSNE(t *testing.T) {
+	// Two well-separated clusters should stay separated.
+	var vecs [][]float64
+	for i := 0; i < 40; i++ {
+		offset := float64(i%2) * 10
+		vecs = append(vecs, []float64{offset + rand.Float64(), offset + rand.Float64(),
+			rand.Float64()})
+	}
+	coords := (&TSNE{Dims: 2, Perplexity: 5, Iters: 300}).Embed(vecs)
+	var intra, inter float64
+	for i, c1 := range coords {
+		for j, c2 := range coords {
+			dist := math.Pow(c1[0]-c2[0], 2) + math.Pow(c1[1]-c2[1], 2)
+			if i%2 == j%2 {
+				intra += dist
+			} else {
+				inter += dist
+			}
+		}
+	}
+	if intra >= inter {
+		t.Errorf("clusters not separated: intra=%f inter=%f", intra, inter)
+	}
+}
+
+func TestWritePlot(t *testing.T) {
+	var buf bytes.Buffer
+	coords := [][]float64{{0, 0}, {1, 2}}
+	labels := []string{"@bob: <b>hi</b>", "@joe"}
+	if err := WritePlot(&buf, "Test", coords, labels); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	if strings.Count(out, "<circle") != 2 {
+		t.Errorf("expected two points in output: %s", out)
+	}
+	if strings.Contains(out, "<b>hi</b>") || !strings.Contains(out, "&lt;b&gt;hi&lt;/b&gt;") {
+		t.Errorf("labels were not escaped: %s", out)
+	}
+}
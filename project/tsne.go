@@ -0,0 +1,165 @@
+package main
+
+import (
+	"math"
+	"math/rand"
+)
+
+// TSNE computes an exact t-SNE embedding.
+//
+// This takes quadratic time and memory in the number of
+// points, so it is only suitable for a few thousand
+// points.
+type TSNE struct {
+	Dims       int
+	Perplexity float64
+	Iters      int
+
+	// StepSize is the gradient descent step size.
+	// If it is 0, a step size proportional to the number
+	// of points is used.
+	StepSize float64
+}
+
+const tsneExaggeration = 12
+
+// Embed computes low-dimensional coordinates for the
+// vectors.
+func (t *TSNE) Embed(vecs [][]float64) [][]float64 {
+	n := len(vecs)
+	p := t.affinities(vecs)
+	stepSize := t.StepSize
+	if stepSize == 0 {
+		stepSize = float64(n) / (4 * tsneExaggeration)
+	}
+
+	res := make([][]float64, n)
+	gains := make([][]float64, n)
+	velocity := make([][]float64, n)
+	for i := range res {
+		res[i] = make([]float64, t.Dims)
+		gains[i] = make([]float64, t.Dims)
+		velocity[i] = make([]float64, t.Dims)
+		for j := range res[i] {
+			res[i][j] = rand.NormFloat64() * 1e-4
+			gains[i][j] = 1
+		}
+	}
+
+	q := make([][]float64, n)
+	for i := range q {
+		q[i] = make([]float64, n)
+	}
+	for iter := 0; iter < t.Iters; iter++ {
+		exaggeration, momentum := 1.0, 0.8
+		if iter < 100 {
+			exaggeration, momentum = tsneExaggeration, 0.5
+		}
+
+		var qSum float64
+		for i := 0; i < n; i++ {
+			for j := i + 1; j < n; j++ {
+				var dist float64
+				for d := 0; d < t.Dims; d++ {
+					diff := res[i][d] - res[j][d]
+					dist += diff * diff
+				}
+				q[i][j] = 1 / (1 + dist)
+				q[j][i] = q[i][j]
+				qSum += 2 * q[i][j]
+			}
+		}
+
+		grads := make([][]float64, n)
+		for i := range grads {
+			grads[i] = make([]float64, t.Dims)
+			for j := 0; j < n; j++ {
+				if i == j {
+					continue
+				}
+				scale := 4 * (exaggeration*p[i][j] - q[i][j]/qSum) * q[i][j]
+				for d := range grads[i] {
+					grads[i][d] += scale * (res[i][d] - res[j][d])
+				}
+			}
+		}
+		for i, grad := range grads {
+			for d, g := range grad {
+				if (g > 0) != (velocity[i][d] > 0) {
+					gains[i][d] += 0.2
+				} else {
+					gains[i][d] = math.Max(gains[i][d]*0.8, 0.01)
+				}
+				velocity[i][d] = momentum*velocity[i][d] - stepSize*gains[i][d]*g
+				res[i][d] += velocity[i][d]
+			}
+		}
+	}
+	return res
+}
+
+// affinities computes the symmetric input similarities,
+// choosing a Gaussian bandwidth for each point to match
+// the perplexity.
+func (t *TSNE) affinities(vecs [][]float64) [][]float64 {
+	n := len(vecs)
+	dists := make([][]float64, n)
+	for i := range dists {
+		dists[i] = make([]float64, n)
+		for j := range dists[i] {
+			for k, x := range vecs[i] {
+				diff := x - vecs[j][k]
+				dists[i][j] += diff * diff
+			}
+		}
+	}
+
+	targetEntropy := math.Log(t.Perplexity)
+	cond := make([][]float64, n)
+	for i := range cond {
+		cond[i] = make([]float64, n)
+		beta, minBeta, maxBeta := 1.0, 0.0, math.Inf(1)
+		for step := 0; step < 50; step++ {
+			var sum, weighted float64
+			for j, d := range dists[i] {
+				if i == j {
+					cond[i][j] = 0
+					continue
+				}
+				cond[i][j] = math.Exp(-beta * d)
+				sum += cond[i][j]
+				weighted += d * cond[i][j]
+			}
+			if sum == 0 {
+				sum = 1e-12
+			}
+			entropy := math.Log(sum) + beta*weighted/sum
+			for j := range cond[i] {
+				cond[i][j] /= sum
+			}
+			if math.Abs(entropy-targetEntropy) < 1e-5 {
+				break
+			}
+			if entropy > targetEntropy {
+				minBeta = beta
+				if math.IsInf(maxBeta, 1) {
+					beta *= 2
+				} else {
+					beta = (beta + maxBeta) / 2
+				}
+			} else {
+				maxBeta = beta
+				beta = (beta + minBeta) / 2
+			}
+		}
+	}
+
+	p := make([][]float64, n)
+	for i := range p {
+		p[i] = make([]float64, n)
+		for j := range p[i] {
+			p[i][j] = math.Max((cond[i][j]+cond[j][i])/(2*float64(n)), 1e-12)
+		}
+	}
+	return p
+}
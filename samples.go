@@ -2,6 +2,7 @@ package tweeters
 
 import (
 	"math/rand"
+	"sort"
 
 	"github.com/unixpickle/essentials"
 )
@@ -16,6 +17,17 @@ type Samples struct {
 	// This might not include all users in the case of a
 	// partitioned sample list.
 	UserIndices []int
+
+	// Truncation, if non-nil, is applied to every tweet.
+	// It should match the truncation of the model being
+	// trained or evaluated.
+	Truncation *Truncation
+
+	// Augmentation, if non-nil, is applied to every tweet
+	// before truncation.
+	// It should only be used for training, and it is not
+	// copied by Partition.
+	Augmentation *Augmentation
 }
 
 // NewSamples creates a Samples with all of the user
@@ -34,8 +46,8 @@ func (s *Samples) Partition(testingFrac float64) (training, testing *Samples) {
 	src := rand.NewSource(1337)
 	users := rand.New(src).Perm(s.DB.NumUsers())
 	testingCount := int(float64(len(users)) * testingFrac)
-	return &Samples{DB: s.DB, UserIndices: users[testingCount:]},
-		&Samples{DB: s.DB, UserIndices: users[:testingCount]}
+	return &Samples{DB: s.DB, UserIndices: users[testingCount:], Truncation: s.Truncation},
+		&Samples{DB: s.DB, UserIndices: users[:testingCount], Truncation: s.Truncation}
 }
 
 // Batch produces a training or validation batch.
@@ -60,28 +72,135 @@ func (s *Samples) Partition(testingFrac float64) (training, testing *Samples) {
 // fed into the classifier.
 func (s *Samples) Batch(p float64, batchSize, min, max int) (tweets [][]byte, avg []int,
 	outs []float64, err error) {
+	b, err := s.LabeledBatch(p, batchSize, min, max)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return b.Tweets, b.Avg, b.Outs, nil
+}
+
+// LabeledBatch is like Batch, but it also records the
+// user of each group of tweets.
+func (s *Samples) LabeledBatch(p float64, batchSize, min, max int) (*LabeledBatch,
+	error) {
 	if min < 2 {
 		panic("invalid min argument")
 	}
-	for len(tweets) < batchSize {
-		t, err := s.RandomUserTweets(min, max)
+	res := &LabeledBatch{}
+	for len(res.Tweets) < batchSize {
+		t, out, user, err := s.randomGroup(p, min, max)
 		if err != nil {
-			return nil, nil, nil, err
+			return nil, err
 		}
-		if rand.Float64() < p {
-			outs = append(outs, 1)
-		} else {
-			newTs, err := s.RandomUserTweets(1, 1)
-			if err != nil {
-				return nil, nil, nil, err
-			}
-			t[len(t)-1] = newTs[0]
-			outs = append(outs, 0)
+		res.Tweets = append(res.Tweets, t...)
+		res.Avg = append(res.Avg, len(t)-1, 1)
+		res.Outs = append(res.Outs, out)
+		res.Users = append(res.Users, user)
+	}
+	return res, nil
+}
+
+// A LabeledBatch stores the results of Samples.LabeledBatch.
+type LabeledBatch struct {
+	Tweets [][]byte
+	Avg    []int
+	Outs   []float64
+
+	// Users stores the DB index of the user whose tweets
+	// make up each group's context.
+	Users []int
+}
+
+// BucketedBatches produces numBatches batches like Batch,
+// but groups users with similarly long tweets into the
+// same batches.
+//
+// Since the encoder processes every tweet in a batch for
+// as many timesteps as the longest tweet takes, this
+// reduces the amount of wasted computation.
+//
+// As with Batch, the batchSize is a soft-limit.
+// Batches are split wherever the total number of tweets
+// reaches a multiple of batchSize, and the final batch
+// takes whatever is left, so no batch is much smaller
+// than the others.
+// If max is greater than batchSize, there may be fewer
+// than numBatches batches.
+//
+// The batches are returned in random order.
+func (s *Samples) BucketedBatches(p float64, batchSize, min, max,
+	numBatches int) ([]*LabeledBatch, error) {
+	if min < 2 {
+		panic("invalid min argument")
+	}
+	type group struct {
+		tweets [][]byte
+		out    float64
+		user   int
+		maxLen int
+	}
+	var groups []*group
+	var numTweets int
+	for numTweets < batchSize*numBatches {
+		t, out, user, err := s.randomGroup(p, min, max)
+		if err != nil {
+			return nil, err
+		}
+		g := &group{tweets: t, out: out, user: user}
+		for _, tweet := range t {
+			g.maxLen = essentials.MaxInt(g.maxLen, len(tweet))
 		}
-		tweets = append(tweets, t...)
-		avg = append(avg, len(t)-1, 1)
+		groups = append(groups, g)
+		numTweets += len(t)
+	}
+	sort.SliceStable(groups, func(i, j int) bool {
+		return groups[i].maxLen < groups[j].maxLen
+	})
+
+	var res []*LabeledBatch
+	batch := &LabeledBatch{}
+	numTweets = 0
+	for _, g := range groups {
+		batch.Tweets = append(batch.Tweets, g.tweets...)
+		batch.Avg = append(batch.Avg, len(g.tweets)-1, 1)
+		batch.Outs = append(batch.Outs, g.out)
+		batch.Users = append(batch.Users, g.user)
+		numTweets += len(g.tweets)
+		if len(res)+1 < numBatches && numTweets >= (len(res)+1)*batchSize {
+			res = append(res, batch)
+			batch = &LabeledBatch{}
+		}
+	}
+	if len(batch.Tweets) > 0 {
+		res = append(res, batch)
+	}
+	shuffled := make([]*LabeledBatch, len(res))
+	for i, j := range rand.Perm(len(res)) {
+		shuffled[i] = res[j]
+	}
+	return shuffled, nil
+}
+
+// randomGroup selects a random user's tweets, replacing
+// the final tweet with another user's tweet with
+// probability 1-p.
+//
+// It returns the tweets, the desired classifier output,
+// and the DB index of the user.
+func (s *Samples) randomGroup(p float64, min, max int) ([][]byte, float64, int, error) {
+	t, user, err := s.randomUserTweets(min, max, -1)
+	if err != nil {
+		return nil, 0, 0, err
 	}
-	return
+	if rand.Float64() < p {
+		return t, 1, user, nil
+	}
+	newTs, err := s.RandomUserTweets(1, 1)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	t[len(t)-1] = newTs[0]
+	return t, 0, user, nil
 }
 
 // RandomUserTweets randomly selects a subset of a random
@@ -90,11 +209,23 @@ func (s *Samples) Batch(p float64, batchSize, min, max int) (tweets [][]byte, av
 // The min and max arguments limit the number of tweets to
 // the range [min, max].
 func (s *Samples) RandomUserTweets(min, max int) ([][]byte, error) {
+	res, _, err := s.randomUserTweets(min, max, -1)
+	return res, err
+}
+
+// randomUserTweets is like RandomUserTweets, but it also
+// returns the DB index of the user, and it never selects
+// the user with the DB index exclude unless there are no
+// other users.
+func (s *Samples) randomUserTweets(min, max, exclude int) ([][]byte, int, error) {
 	for {
 		userIdx := s.UserIndices[rand.Intn(len(s.UserIndices))]
+		if userIdx == exclude && len(s.UserIndices) > 1 {
+			continue
+		}
 		records, err := s.DB.Read(userIdx)
 		if err != nil {
-			return nil, err
+			return nil, 0, err
 		}
 		if len(records) < min {
 			continue
@@ -104,8 +235,66 @@ func (s *Samples) RandomUserTweets(min, max int) ([][]byte, error) {
 		randIdx := rand.Perm(len(records))[:numTake]
 		res := make([][]byte, len(randIdx))
 		for i, j := range randIdx {
-			res[i] = records[j].Body
+			res[i] = s.body(records[j])
+		}
+		return res, userIdx, nil
+	}
+}
+
+// RandomTweets selects n tweets, each from a random user.
+func (s *Samples) RandomTweets(n int) ([][]byte, error) {
+	return s.RandomOtherTweets(n, -1)
+}
+
+// RandomOtherTweets is like RandomTweets, but it avoids
+// the user with the given DB index.
+func (s *Samples) RandomOtherTweets(n, user int) ([][]byte, error) {
+	var res [][]byte
+	for len(res) < n {
+		t, _, err := s.randomUserTweets(1, 1, user)
+		if err != nil {
+			return nil, err
 		}
-		return res, nil
+		res = append(res, t...)
+	}
+	return res, nil
+}
+
+// RandomUsers selects n distinct users at random and
+// returns all of their tweets, in random order.
+//
+// Only users with at least min tweets are selected.
+// If there are not enough such users, fewer than n users
+// are returned.
+func (s *Samples) RandomUsers(n, min int) ([][][]byte, error) {
+	var res [][][]byte
+	for _, i := range rand.Perm(len(s.UserIndices)) {
+		if len(res) == n {
+			break
+		}
+		records, err := s.DB.Read(s.UserIndices[i])
+		if err != nil {
+			return nil, err
+		}
+		if len(records) < min {
+			continue
+		}
+		tweets := make([][]byte, len(records))
+		for j, k := range rand.Perm(len(records)) {
+			tweets[j] = s.body(records[k])
+		}
+		res = append(res, tweets)
+	}
+	return res, nil
+}
+
+func (s *Samples) body(r Record) []byte {
+	body := r.Body
+	if s.Augmentation != nil {
+		body = s.Augmentation.Augment(body)
+	}
+	if s.Truncation != nil {
+		body = s.Truncation.Truncate(body)
 	}
+	return body
 }
@@ -0,0 +1,156 @@
+package tweeters
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strconv"
+	"testing"
+
+	"github.com/unixpickle/anyvec/anyvec64"
+)
+
+func TestBucketedBatches(t *testing.T) {
+	db, cleanup := lengthTestDB(t)
+	defer cleanup()
+
+	batches, err := NewSamples(db).BucketedBatches(0.5, 16, 2, 4, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(batches) != 5 {
+		t.Fatalf("expected 5 batches but got %d", len(batches))
+	}
+
+	// Each batch ends less than max tweets after a
+	// multiple of the batch size.
+	type lengthRange struct {
+		min, max int
+	}
+	var ranges []lengthRange
+	for i, batch := range batches {
+		if len(batch.Tweets) < 16-3 || len(batch.Tweets) > 16+3 {
+			t.Errorf("batch %d: unexpected size %d", i, len(batch.Tweets))
+		}
+		var groupLens []int
+		var offset int
+		for j := 0; j < len(batch.Avg); j += 2 {
+			var maxLen int
+			for _, tweet := range batch.Tweets[offset : offset+batch.Avg[j]+batch.Avg[j+1]] {
+				if len(tweet) > maxLen {
+					maxLen = len(tweet)
+				}
+			}
+			groupLens = append(groupLens, maxLen)
+			offset += batch.Avg[j] + batch.Avg[j+1]
+		}
+		if !sort.IntsAreSorted(groupLens) {
+			t.Errorf("batch %d: lengths are not sorted: %v", i, groupLens)
+		}
+		ranges = append(ranges, lengthRange{groupLens[0], groupLens[len(groupLens)-1]})
+	}
+
+	sort.Slice(ranges, func(i, j int) bool {
+		return ranges[i].min < ranges[j].min
+	})
+	for i := 1; i < len(ranges); i++ {
+		if ranges[i].min < ranges[i-1].max {
+			t.Errorf("overlapping length ranges: %v", ranges)
+			break
+		}
+	}
+}
+
+func TestRandomOtherTweets(t *testing.T) {
+	db, cleanup := lengthTestDB(t)
+	defer cleanup()
+
+	// User i's tweets have lengths in [1+i*5, 5+i*5].
+	samples := &Samples{DB: db, UserIndices: []int{0, 5}}
+	tweets, err := samples.RandomOtherTweets(20, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tweets) != 20 {
+		t.Fatalf("expected 20 tweets but got %d", len(tweets))
+	}
+	for _, tweet := range tweets {
+		if len(tweet) <= 5 {
+			t.Fatalf("got tweet %q from excluded user", tweet)
+		}
+	}
+}
+
+func BenchmarkBucketedBatches(b *testing.B) {
+	db, cleanup := lengthTestDB(b)
+	defer cleanup()
+	samples := NewSamples(db)
+	model := NewModel(anyvec64.CurrentCreator(), nil, 0, 64, 1)
+
+	const numBatches = 5
+	bucketed, err := samples.BucketedBatches(0.5, 16, 2, 4, numBatches)
+	if err != nil {
+		b.Fatal(err)
+	}
+	var bucketedBatches, randomBatches [][][]byte
+	for _, batch := range bucketed {
+		bucketedBatches = append(bucketedBatches, batch.Tweets)
+		tweets, _, _, err := samples.Batch(0.5, 16, 2, 4)
+		if err != nil {
+			b.Fatal(err)
+		}
+		randomBatches = append(randomBatches, tweets)
+	}
+
+	for _, mode := range []struct {
+		name    string
+		batches [][][]byte
+	}{{"Random", randomBatches}, {"Bucketed", bucketedBatches}} {
+		b.Run(mode.name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				for _, tweets := range mode.batches {
+					model.Encode(tweets)
+				}
+			}
+		})
+	}
+}
+
+// lengthTestDB creates a database where each user's
+// tweets have similar lengths, but different users have
+// very different lengths.
+//
+// The returned function closes and deletes the database.
+func lengthTestDB(t testing.TB) (*DB, func()) {
+	records := make(chan Record, 100)
+	for i := 0; i < 20; i++ {
+		for j := 0; j < 5; j++ {
+			records <- Record{
+				User: []byte("user" + strconv.Itoa(i)),
+				Body: bytes.Repeat([]byte("x"), 1+i*5+j),
+			}
+		}
+	}
+	close(records)
+
+	f, err := ioutil.TempFile("", "samplestest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = WriteDB(f, records)
+	f.Close()
+	if err != nil {
+		os.Remove(f.Name())
+		t.Fatal(err)
+	}
+	db, err := OpenDB(f.Name())
+	if err != nil {
+		os.Remove(f.Name())
+		t.Fatal(err)
+	}
+	return db, func() {
+		db.Close()
+		os.Remove(f.Name())
+	}
+}
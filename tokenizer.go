@@ -0,0 +1,319 @@
+package tweeters
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/fnv"
+	"unicode/utf8"
+
+	"github.com/unixpickle/essentials"
+	"github.com/unixpickle/serializer"
+)
+
+func init() {
+	serializer.RegisterTypedDeserializer((&ByteTokenizer{}).SerializerType(),
+		DeserializeByteTokenizer)
+	serializer.RegisterTypedDeserializer((&CodePointTokenizer{}).SerializerType(),
+		DeserializeCodePointTokenizer)
+	serializer.RegisterTypedDeserializer((&BPETokenizer{}).SerializerType(),
+		DeserializeBPETokenizer)
+}
+
+// A Token is a single encoder input.
+type Token struct {
+	// ID is the index of the token in the vocabulary.
+	ID int
+
+	// Start and End specify the range of bytes in the
+	// tweet that the token covers.
+	Start int
+	End   int
+}
+
+// A Tokenizer converts tweets into sequences of tokens.
+type Tokenizer interface {
+	serializer.Serializer
+
+	// Tokenize splits a tweet into tokens.
+	// The tokens cover the entire tweet, in order.
+	Tokenize(tweet []byte) []Token
+
+	// VocabSize returns the number of possible token IDs.
+	VocabSize() int
+}
+
+// NewTokenizer creates a Tokenizer by name.
+//
+// The name may be "bytes", "codepoints", or "bpe".
+// The vocab argument specifies the vocabulary size for
+// the codepoints and bpe tokenizers.
+// The corpus is used to train bpe tokenizers.
+func NewTokenizer(name string, vocab int, corpus [][]byte) (Tokenizer, error) {
+	switch name {
+	case "bytes":
+		return &ByteTokenizer{}, nil
+	case "codepoints":
+		if vocab <= utf8.RuneSelf {
+			return nil, errors.New("codepoints vocabulary must be larger than 128")
+		}
+		return &CodePointTokenizer{Vocab: vocab}, nil
+	case "bpe":
+		if vocab < 0x100 {
+			return nil, errors.New("bpe vocabulary must be at least 256")
+		}
+		return TrainBPE(corpus, vocab-0x100), nil
+	default:
+		return nil, errors.New("unknown tokenizer: " + name)
+	}
+}
+
+// ByteTokenizer produces one token per byte.
+//
+// This is the tokenizer used by models which do not
+// specify one.
+type ByteTokenizer struct{}
+
+// DeserializeByteTokenizer deserializes a ByteTokenizer.
+func DeserializeByteTokenizer(d []byte) (*ByteTokenizer, error) {
+	return &ByteTokenizer{}, nil
+}
+
+// Tokenize produces one token per byte.
+func (b *ByteTokenizer) Tokenize(tweet []byte) []Token {
+	res := make([]Token, len(tweet))
+	for i, x := range tweet {
+		res[i] = Token{ID: int(x), Start: i, End: i + 1}
+	}
+	return res
+}
+
+// VocabSize returns 256.
+func (b *ByteTokenizer) VocabSize() int {
+	return 0x100
+}
+
+// SerializerType returns the unique ID used to serialize
+// a ByteTokenizer with the serializer package.
+func (b *ByteTokenizer) SerializerType() string {
+	return "github.com/unixpickle/tweeters.ByteTokenizer"
+}
+
+// Serialize serializes the ByteTokenizer.
+func (b *ByteTokenizer) Serialize() ([]byte, error) {
+	return []byte{}, nil
+}
+
+// CodePointTokenizer produces one token per Unicode code
+// point.
+//
+// ASCII characters get their own tokens.
+// All other code points (including invalid UTF-8 bytes)
+// are hashed into the remaining tokens.
+type CodePointTokenizer struct {
+	// Vocab is the total number of tokens.
+	// It must be greater than 128.
+	Vocab int
+}
+
+// DeserializeCodePointTokenizer deserializes a
+// CodePointTokenizer.
+func DeserializeCodePointTokenizer(d []byte) (*CodePointTokenizer, error) {
+	var vocab int32
+	if err := binary.Read(bytes.NewReader(d), dbByteOrder, &vocab); err != nil {
+		return nil, essentials.AddCtx("deserialize CodePointTokenizer", err)
+	}
+	return &CodePointTokenizer{Vocab: int(vocab)}, nil
+}
+
+// Tokenize produces one token per code point.
+func (c *CodePointTokenizer) Tokenize(tweet []byte) []Token {
+	var res []Token
+	for i := 0; i < len(tweet); {
+		r, size := utf8.DecodeRune(tweet[i:])
+		tok := Token{ID: int(r), Start: i, End: i + size}
+		if r >= utf8.RuneSelf || size != 1 {
+			hash := fnv.New32a()
+			hash.Write(tweet[i : i+size])
+			tok.ID = utf8.RuneSelf + int(hash.Sum32()%uint32(c.Vocab-utf8.RuneSelf))
+		}
+		res = append(res, tok)
+		i += size
+	}
+	return res
+}
+
+// VocabSize returns c.Vocab.
+func (c *CodePointTokenizer) VocabSize() int {
+	return c.Vocab
+}
+
+// SerializerType returns the unique ID used to serialize
+// a CodePointTokenizer with the serializer package.
+func (c *CodePointTokenizer) SerializerType() string {
+	return "github.com/unixpickle/tweeters.CodePointTokenizer"
+}
+
+// Serialize serializes the CodePointTokenizer.
+func (c *CodePointTokenizer) Serialize() ([]byte, error) {
+	var buf bytes.Buffer
+	binary.Write(&buf, dbByteOrder, int32(c.Vocab))
+	return buf.Bytes(), nil
+}
+
+// BPETokenizer is a byte-pair encoding.
+//
+// Tokens 0 through 255 are raw bytes, and each merge adds
+// one more token to the vocabulary.
+type BPETokenizer struct {
+	// Merges lists the pairs of tokens to merge, in order
+	// of priority.
+	// Merge i produces token 256+i.
+	Merges [][2]int
+
+	// ranks maps merges to their indices.
+	// If it is nil, it is recomputed for every call to
+	// Tokenize.
+	ranks map[[2]int]int
+}
+
+// TrainBPE learns a byte-pair encoding from a corpus.
+//
+// At each step, the most frequent pair of adjacent tokens
+// is merged into a new token.
+// Training stops after numMerges merges, or when no pair
+// occurs more than once.
+func TrainBPE(tweets [][]byte, numMerges int) *BPETokenizer {
+	var merges [][2]int
+	seqs := make([][]int, len(tweets))
+	for i, tweet := range tweets {
+		for _, b := range tweet {
+			seqs[i] = append(seqs[i], int(b))
+		}
+	}
+	for len(merges) < numMerges {
+		counts := map[[2]int]int{}
+		for _, seq := range seqs {
+			for i := 1; i < len(seq); i++ {
+				counts[[2]int{seq[i-1], seq[i]}]++
+			}
+		}
+		var best [2]int
+		var bestCount int
+		for pair, count := range counts {
+			if count > bestCount || (count == bestCount && lessPair(pair, best)) {
+				best, bestCount = pair, count
+			}
+		}
+		if bestCount < 2 {
+			break
+		}
+		newID := 0x100 + len(merges)
+		merges = append(merges, best)
+		for i, seq := range seqs {
+			seqs[i] = mergePair(seq, best, newID)
+		}
+	}
+	return NewBPETokenizer(merges)
+}
+
+// NewBPETokenizer creates a BPETokenizer from a list of
+// merges.
+func NewBPETokenizer(merges [][2]int) *BPETokenizer {
+	res := &BPETokenizer{Merges: merges, ranks: map[[2]int]int{}}
+	for i, pair := range merges {
+		res.ranks[pair] = i
+	}
+	return res
+}
+
+// DeserializeBPETokenizer deserializes a BPETokenizer.
+func DeserializeBPETokenizer(d []byte) (*BPETokenizer, error) {
+	if len(d)%8 != 0 {
+		return nil, errors.New("deserialize BPETokenizer: invalid data length")
+	}
+	var merges [][2]int
+	r := bytes.NewReader(d)
+	for i := 0; i < len(d)/8; i++ {
+		var pair [2]int32
+		if err := binary.Read(r, dbByteOrder, &pair); err != nil {
+			return nil, essentials.AddCtx("deserialize BPETokenizer", err)
+		}
+		merges = append(merges, [2]int{int(pair[0]), int(pair[1])})
+	}
+	return NewBPETokenizer(merges), nil
+}
+
+// Tokenize applies the merges to the bytes of a tweet.
+func (b *BPETokenizer) Tokenize(tweet []byte) []Token {
+	ranks := b.ranks
+	if ranks == nil {
+		ranks = NewBPETokenizer(b.Merges).ranks
+	}
+	tokens := (&ByteTokenizer{}).Tokenize(tweet)
+	for {
+		bestRank := -1
+		for i := 1; i < len(tokens); i++ {
+			pair := [2]int{tokens[i-1].ID, tokens[i].ID}
+			if rank, ok := ranks[pair]; ok && (bestRank < 0 || rank < bestRank) {
+				bestRank = rank
+			}
+		}
+		if bestRank < 0 {
+			return tokens
+		}
+		pair := b.Merges[bestRank]
+		var merged []Token
+		for i := 0; i < len(tokens); i++ {
+			if i+1 < len(tokens) && tokens[i].ID == pair[0] && tokens[i+1].ID == pair[1] {
+				merged = append(merged, Token{
+					ID:    0x100 + bestRank,
+					Start: tokens[i].Start,
+					End:   tokens[i+1].End,
+				})
+				i++
+			} else {
+				merged = append(merged, tokens[i])
+			}
+		}
+		tokens = merged
+	}
+}
+
+// VocabSize returns the number of bytes plus the number
+// of merges.
+func (b *BPETokenizer) VocabSize() int {
+	return 0x100 + len(b.Merges)
+}
+
+// SerializerType returns the unique ID used to serialize
+// a BPETokenizer with the serializer package.
+func (b *BPETokenizer) SerializerType() string {
+	return "github.com/unixpickle/tweeters.BPETokenizer"
+}
+
+// Serialize serializes the BPETokenizer.
+func (b *BPETokenizer) Serialize() ([]byte, error) {
+	var buf bytes.Buffer
+	for _, pair := range b.Merges {
+		binary.Write(&buf, dbByteOrder, [2]int32{int32(pair[0]), int32(pair[1])})
+	}
+	return buf.Bytes(), nil
+}
+
+func mergePair(seq []int, pair [2]int, newID int) []int {
+	var res []int
+	for i := 0; i < len(seq); i++ {
+		if i+1 < len(seq) && seq[i] == pair[0] && seq[i+1] == pair[1] {
+			res = append(res, newID)
+			i++
+		} else {
+			res = append(res, seq[i])
+		}
+	}
+	return res
+}
+
+func lessPair(p1, p2 [2]int) bool {
+	return p1[0] < p2[0] || (p1[0] == p2[0] && p1[1] < p2[1])
+}
@@ -0,0 +1,69 @@
+package tweeters
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCodePointTokenizer(t *testing.T) {
+	tok := &CodePointTokenizer{Vocab: 200}
+	tweet := []byte("hi 😀\xff")
+	tokens := tok.Tokenize(tweet)
+	if len(tokens) != 5 {
+		t.Fatalf("expected 5 tokens but got %d", len(tokens))
+	}
+	for i, expected := range []int{'h', 'i', ' '} {
+		if tokens[i].ID != expected {
+			t.Errorf("token %d: expected %d but got %d", i, expected, tokens[i].ID)
+		}
+	}
+	for _, token := range tokens[3:] {
+		if token.ID < 128 || token.ID >= 200 {
+			t.Errorf("token out of range: %d", token.ID)
+		}
+	}
+	if tokens[3].Start != 3 || tokens[3].End != 7 || tokens[4].End != len(tweet) {
+		t.Errorf("unexpected spans: %v", tokens)
+	}
+	if !reflect.DeepEqual(tokens, tok.Tokenize(tweet)) {
+		t.Error("tokenization is not deterministic")
+	}
+}
+
+func TestBPETokenizer(t *testing.T) {
+	corpus := [][]byte{[]byte("hahaha"), []byte("haha ha"), []byte("oh ha")}
+	tok := TrainBPE(corpus, 3)
+	expectedMerges := [][2]int{{'h', 'a'}, {0x100, 0x100}, {' ', 0x100}}
+	if !reflect.DeepEqual(tok.Merges, expectedMerges) {
+		t.Fatalf("expected merges %v but got %v", expectedMerges, tok.Merges)
+	}
+	if tok.VocabSize() != 0x103 {
+		t.Errorf("unexpected vocab size: %d", tok.VocabSize())
+	}
+
+	actual := tok.Tokenize([]byte("hahah ha"))
+	expected := []Token{
+		{ID: 0x101, Start: 0, End: 4},
+		{ID: 'h', Start: 4, End: 5},
+		{ID: 0x102, Start: 5, End: 8},
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %v but got %v", expected, actual)
+	}
+
+	data, err := tok.Serialize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := DeserializeBPETokenizer(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded.Merges, tok.Merges) {
+		t.Errorf("expected merges %v but got %v", tok.Merges, decoded.Merges)
+	}
+	literal := &BPETokenizer{Merges: tok.Merges}
+	if !reflect.DeepEqual(literal.Tokenize([]byte("hahah ha")), expected) {
+		t.Error("tokenizer without ranks gave different result")
+	}
+}
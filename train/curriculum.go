@@ -0,0 +1,137 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"log"
+
+	"github.com/unixpickle/essentials"
+)
+
+// accuracyWindow is the number of validation batches
+// over which accuracy is averaged for Stage.Accuracy.
+const accuracyWindow = 10
+
+// A Stage is one step of a Curriculum.
+type Stage struct {
+	// MinTweets and MaxTweets limit the number of tweets
+	// per user, including the candidate.
+	MinTweets int `json:"min"`
+	MaxTweets int `json:"max"`
+
+	// Negatives is the number of random candidates from
+	// which each negative example is mined.
+	// See Trainer.Negatives.
+	Negatives int `json:"negatives"`
+
+	// Iters, if non-zero, is the number of iterations
+	// after which to move on to the next stage.
+	Iters int `json:"iters"`
+
+	// Accuracy, if non-zero, is the average validation
+	// accuracy after which to move on to the next stage.
+	// It is only used with BinaryObjective.
+	Accuracy float64 `json:"accuracy"`
+}
+
+// A Curriculum adjusts the difficulty of a Trainer's
+// batches as training progresses.
+//
+// Typically, early stages provide many context tweets and
+// easy negatives, while later stages provide fewer
+// context tweets and harder negatives.
+type Curriculum struct {
+	Stages []*Stage
+
+	stage      int
+	stageIters int
+	accuracies []float64
+}
+
+// LoadCurriculum reads a JSON list of stages.
+func LoadCurriculum(path string) (curr *Curriculum, err error) {
+	defer essentials.AddCtxTo("load curriculum", &err)
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var stages []*Stage
+	if err := json.Unmarshal(data, &stages); err != nil {
+		return nil, err
+	}
+	if len(stages) == 0 {
+		return nil, errors.New("no stages")
+	}
+	for _, stage := range stages {
+		if stage.MinTweets < 2 || stage.MaxTweets < stage.MinTweets {
+			return nil, errors.New("invalid tweet limits")
+		}
+		if stage.Negatives == 0 {
+			stage.Negatives = 1
+		}
+		if stage.Negatives < 0 || stage.Iters < 0 || stage.Accuracy < 0 {
+			return nil, errors.New("invalid stage")
+		}
+	}
+	return &Curriculum{Stages: stages}, nil
+}
+
+// Start applies the first stage to the trainer.
+func (c *Curriculum) Start(t *Trainer) {
+	c.stage = 0
+	c.apply(t)
+}
+
+// Step should be called after every training iteration.
+// It moves on to the next stage once the current stage's
+// iteration limit has been reached.
+func (c *Curriculum) Step(t *Trainer) {
+	c.stageIters++
+	stage := c.Stages[c.stage]
+	if stage.Iters != 0 && c.stageIters >= stage.Iters {
+		c.advance(t)
+	}
+}
+
+// Validated should be called with the accuracy of every
+// validation batch.
+// It moves on to the next stage once the average recent
+// accuracy reaches the current stage's threshold.
+func (c *Curriculum) Validated(t *Trainer, accuracy float64) {
+	c.accuracies = append(c.accuracies, accuracy)
+	if len(c.accuracies) > accuracyWindow {
+		c.accuracies = c.accuracies[1:]
+	}
+	stage := c.Stages[c.stage]
+	if stage.Accuracy == 0 || len(c.accuracies) < accuracyWindow {
+		return
+	}
+	var mean float64
+	for _, acc := range c.accuracies {
+		mean += acc / float64(len(c.accuracies))
+	}
+	if mean >= stage.Accuracy {
+		c.advance(t)
+	}
+}
+
+func (c *Curriculum) advance(t *Trainer) {
+	if c.stage+1 == len(c.Stages) {
+		return
+	}
+	c.stage++
+	c.apply(t)
+}
+
+func (c *Curriculum) apply(t *Trainer) {
+	c.stageIters = 0
+	c.accuracies = nil
+	stage := c.Stages[c.stage]
+	t.MinTweets = stage.MinTweets
+	t.MaxTweets = stage.MaxTweets
+	t.Negatives = stage.Negatives
+	t.pending = nil
+	log.Printf("curriculum stage %d/%d: min=%d max=%d negatives=%d", c.stage+1,
+		len(c.Stages), stage.MinTweets, stage.MaxTweets, stage.Negatives)
+}
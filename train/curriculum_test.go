@@ -0,0 +1,62 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestCurriculum(t *testing.T) {
+	f, err := ioutil.TempFile("", "curriculum")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	_, err = f.Write([]byte(`[
+		{"min": 8, "max": 16, "iters": 3},
+		{"min": 4, "max": 8, "negatives": 4, "accuracy": 0.8},
+		{"min": 2, "max": 8, "negatives": 8}
+	]`))
+	f.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	curriculum, err := LoadCurriculum(f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var trainer Trainer
+	check := func(min, max, negatives int) {
+		if trainer.MinTweets != min || trainer.MaxTweets != max ||
+			trainer.Negatives != negatives {
+			t.Fatalf("expected (%d, %d, %d) but got (%d, %d, %d)", min, max, negatives,
+				trainer.MinTweets, trainer.MaxTweets, trainer.Negatives)
+		}
+	}
+
+	curriculum.Start(&trainer)
+	check(8, 16, 1)
+	curriculum.Step(&trainer)
+	curriculum.Step(&trainer)
+	check(8, 16, 1)
+	curriculum.Step(&trainer)
+	check(4, 8, 4)
+
+	for i := 0; i < accuracyWindow; i++ {
+		curriculum.Validated(&trainer, 0.7)
+		curriculum.Step(&trainer)
+	}
+	check(4, 8, 4)
+	for i := 0; i < accuracyWindow; i++ {
+		curriculum.Validated(&trainer, 0.9)
+	}
+	check(2, 8, 8)
+
+	// The final stage lasts forever.
+	for i := 0; i < 100; i++ {
+		curriculum.Validated(&trainer, 1)
+		curriculum.Step(&trainer)
+	}
+	check(2, 8, 8)
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"github.com/unixpickle/anydiff"
+	"github.com/unixpickle/anynet/anysgd"
+)
+
+// GroupScaler transforms the gradients of a group of
+// variables separately from the other variables, and then
+// scales the group's transformed gradients.
+//
+// This can be used to give a group of parameters, such as
+// a pre-trained encoder, a lower learning rate than the
+// rest of the model.
+//
+// Since each group has its own Transformer, the group's
+// variables may be missing from the gradient for a while
+// (e.g. while they are frozen).
+// A stateful GroupTransformer like Adam then starts fresh
+// once the variables appear.
+type GroupScaler struct {
+	// Transformer is used for variables not in Vars.
+	Transformer anysgd.Transformer
+
+	// GroupTransformer is used for variables in Vars.
+	GroupTransformer anysgd.Transformer
+
+	Vars  anydiff.VarSet
+	Scale float64
+}
+
+// Transform applies the wrapped Transformers and then
+// scales the gradients of g.Vars.
+func (g *GroupScaler) Transform(grad anydiff.Grad) anydiff.Grad {
+	group, rest := anydiff.Grad{}, anydiff.Grad{}
+	for v, vec := range grad {
+		if g.Vars.Has(v) {
+			group[v] = vec
+		} else {
+			rest[v] = vec
+		}
+	}
+	res := anydiff.Grad{}
+	if len(rest) > 0 {
+		for v, vec := range g.Transformer.Transform(rest) {
+			res[v] = vec
+		}
+	}
+	if len(group) > 0 {
+		for v, vec := range g.GroupTransformer.Transform(group) {
+			vec.Scale(vec.Creator().MakeNumeric(g.Scale))
+			res[v] = vec
+		}
+	}
+	return res
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"math"
+	"testing"
+
+	"github.com/unixpickle/anydiff"
+	"github.com/unixpickle/anynet/anysgd"
+	"github.com/unixpickle/anyvec/anyvec64"
+	"github.com/unixpickle/tweeters"
+)
+
+func TestGroupScaler(t *testing.T) {
+	c := anyvec64.CurrentCreator()
+	v1 := anydiff.NewVar(c.MakeVectorData([]float64{1, 2}))
+	v2 := anydiff.NewVar(c.MakeVectorData([]float64{3}))
+	rest, group := &countTransformer{}, &countTransformer{}
+	scaler := &GroupScaler{
+		Transformer:      rest,
+		GroupTransformer: group,
+		Vars:             anydiff.NewVarSet(v2),
+		Scale:            0.5,
+	}
+
+	res := scaler.Transform(anydiff.Grad{v1: c.MakeVectorData([]float64{2, -4})})
+	if len(res) != 1 || group.calls != 0 {
+		t.Fatalf("unexpected result %v with %d group calls", res, group.calls)
+	}
+	res = scaler.Transform(anydiff.Grad{
+		v1: c.MakeVectorData([]float64{2, -4}),
+		v2: c.MakeVectorData([]float64{6}),
+	})
+	if rest.calls != 2 || group.calls != 1 {
+		t.Errorf("unexpected calls: %d and %d", rest.calls, group.calls)
+	}
+	if actual := tweeters.VectorFloats(res[v1]); actual[0] != 2 || actual[1] != -4 {
+		t.Errorf("unexpected ungrouped gradient: %v", actual)
+	}
+	if actual := tweeters.VectorFloats(res[v2]); actual[0] != 3 {
+		t.Errorf("unexpected grouped gradient: %v", actual)
+	}
+}
+
+func TestFreezeIters(t *testing.T) {
+	c := anyvec64.CurrentCreator()
+	model := tweeters.NewModel(c, nil, 0, 16, 1)
+	batch := &Batch{
+		Tweets: [][]byte{[]byte("hello"), []byte("world"), []byte("hi"),
+			[]byte("foo"), []byte("bar")},
+		Avg: []int{2, 1, 1, 1},
+		Out: anydiff.NewConst(c.MakeVectorData([]float64{1, 0})),
+	}
+	trainer := &Trainer{Model: model, Objective: BinaryObjective, FreezeIters: 2}
+	encoder := anydiff.NewVarSet(model.EncoderParameters()...)
+	scaler := &GroupScaler{
+		Transformer:      &anysgd.Adam{},
+		GroupTransformer: &anysgd.Adam{},
+		Vars:             encoder,
+		Scale:            0.5,
+	}
+
+	numClassifier := len(model.ClassifierParameters())
+	for i := 0; i < 2; i++ {
+		grad := trainer.Gradient(batch)
+		if len(grad) != numClassifier {
+			t.Fatalf("iter %d: expected %d gradients but got %d", i, numClassifier,
+				len(grad))
+		}
+		for v := range grad {
+			if encoder.Has(v) {
+				t.Fatalf("iter %d: encoder should be frozen", i)
+			}
+		}
+		scaler.Transform(grad)
+	}
+
+	grad := trainer.Gradient(batch)
+	if len(grad) != len(model.Parameters()) {
+		t.Fatalf("expected %d gradients but got %d", len(model.Parameters()), len(grad))
+	}
+	raw := map[*anydiff.Var][]float64{}
+	for v, vec := range grad {
+		raw[v] = append([]float64{}, tweeters.VectorFloats(vec)...)
+	}
+
+	// The first step of a fresh Adam has unit magnitude in
+	// every non-zero direction.
+	for v, vec := range scaler.Transform(grad) {
+		if !encoder.Has(v) {
+			continue
+		}
+		for i, x := range tweeters.VectorFloats(vec) {
+			if math.Abs(raw[v][i]) > 1e-5 && math.Abs(math.Abs(x)-0.5) > 1e-3 {
+				t.Fatalf("unexpected encoder step %f for gradient %e", x, raw[v][i])
+			}
+		}
+	}
+}
+
+type countTransformer struct {
+	calls int
+}
+
+func (c *countTransformer) Transform(g anydiff.Grad) anydiff.Grad {
+	c.calls++
+	return g
+}
@@ -1,13 +1,15 @@
 package main
 
 import (
+	"errors"
 	"flag"
 	"log"
 	"math/rand"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/unixpickle/anydiff"
-	"github.com/unixpickle/anynet"
 	"github.com/unixpickle/anynet/anysgd"
 	"github.com/unixpickle/anyvec"
 	"github.com/unixpickle/anyvec/anyvec32"
@@ -25,33 +27,82 @@ func main() {
 	var modelPath string
 	var samplesPath string
 	var stepSize float64
+	var encoderStep float64
+	var aug tweeters.Augmentation
+	var curriculumPath string
 	var validation float64
-	var hidden int
-	var dropout float64
+	var tokenizer string
+	var vocab int
+	var bpeSamples int
+	var archPath string
+	var classifier string
+	arch := tweeters.DefaultArchitecture()
 
 	flag.StringVar(&modelPath, "out", "model_out", "path to model file")
 	flag.StringVar(&samplesPath, "data", "", "path to tweet database")
 	flag.IntVar(&sgd.BatchSize, "batch", 64, "batch size")
 	flag.Float64Var(&stepSize, "step", 0.001, "SGD step size")
+	flag.Float64Var(&encoderStep, "encstep", 0,
+		"step size for encoder parameters (default: same as -step)")
+	flag.IntVar(&trainer.FreezeIters, "freeze", 0,
+		"number of initial iterations to train only the classifier")
 	flag.IntVar(&trainer.MinTweets, "min", 3, "minimum tweets per user")
 	flag.IntVar(&trainer.MaxTweets, "max", 16, "maximum tweets per user")
 	flag.Float64Var(&trainer.UserProb, "prob", 0.5, "probability of same user")
+	flag.IntVar(&trainer.Negatives, "negatives", 1,
+		"number of random candidates to mine each negative from")
+	flag.StringVar(&curriculumPath, "curriculum", "",
+		"JSON curriculum (overrides -min, -max, and -negatives)")
+	flag.StringVar(&trainer.Objective, "objective", BinaryObjective,
+		"training objective (binary, infonce, or triplet)")
+	flag.Float64Var(&trainer.Margin, "margin", 0.2, "margin for the triplet objective")
+	flag.Float64Var(&trainer.LMWeight, "lmweight", 0, "weight of auxiliary language modeling cost")
 	flag.Float64Var(&validation, "validation", 0.1, "validation fraction")
-	flag.IntVar(&hidden, "hidden", 512, "state size for new networks")
-	flag.Float64Var(&dropout, "dropout", 1, "dropout keep probability")
+	flag.IntVar(&trainer.Buckets, "buckets", 0,
+		"number of batches to bucket by tweet length at once (0 to disable)")
+	flag.IntVar(&trainer.Workers, "workers", 1,
+		"number of CPU goroutines to compute gradients with (all share one device)")
+	flag.StringVar(&archPath, "arch", "",
+		"JSON architecture for new networks (overrides architecture flags)")
+	flag.StringVar(&arch.Cell, "cell", arch.Cell, "cell type (lstm, gru, or vanilla)")
+	flag.IntVar(&arch.Layers, "layers", arch.Layers, "number of recurrent layers")
+	flag.IntVar(&arch.ConvLayers, "conv", 0, "number of convolutional layers")
+	flag.IntVar(&arch.ConvWidth, "convwidth", arch.ConvWidth, "convolution kernel width")
+	flag.IntVar(&arch.Hidden, "hidden", arch.Hidden, "state size for new networks")
+	flag.Float64Var(&arch.Dropout, "dropout", arch.Dropout, "dropout keep probability")
+	flag.BoolVar(&arch.Bidirectional, "bidir", false, "use a bidirectional encoder")
+	flag.StringVar(&arch.Pooling, "pooling", arch.Pooling,
+		"readout over time (tail, mean, max, or attention)")
+	flag.StringVar(&arch.Aggregator, "aggregator", arch.Aggregator,
+		"context aggregation (mean or attention)")
+	flag.StringVar(&arch.Features, "features", arch.Features,
+		"classifier features (concat, symmetric, or all)")
+	flag.BoolVar(&arch.Residual, "residual", false, "use residual connections")
+	flag.BoolVar(&arch.LayerNorm, "layernorm", false, "use layer normalization")
+	flag.StringVar(&classifier, "classifier", "512,256",
+		"comma-separated classifier hidden layer sizes")
+	flag.StringVar(&tokenizer, "tokenizer", "bytes",
+		"tokenizer for new networks (bytes, codepoints, or bpe)")
+	flag.IntVar(&vocab, "vocab", 1024, "vocabulary size for codepoints or bpe tokenizers")
+	flag.IntVar(&bpeSamples, "bpesamples", 5000, "tweets used to train bpe tokenizers")
+	flag.IntVar(&arch.EmbedDim, "embed", 0, "embedding size for new networks (0 for one-hot)")
+	flag.Float64Var(&aug.CharDropout, "chardrop", 0, "augmentation: character dropout probability")
+	flag.Float64Var(&aug.CaseFlip, "caseflip", 0, "augmentation: case flip probability")
+	flag.Float64Var(&aug.Typo, "typos", 0, "augmentation: per-character typo probability")
+	flag.Float64Var(&aug.URLs, "urls", 0, "augmentation: URL replacement probability")
+	flag.Float64Var(&aug.Mentions, "mentions", 0, "augmentation: mention replacement probability")
+	flag.Float64Var(&aug.Emoji, "dropemoji", 0, "augmentation: emoji removal probability")
+	flag.IntVar(&arch.MaxLen, "maxlen", 0, "maximum tweet length in bytes for new networks")
+	flag.StringVar(&arch.Truncation, "truncation", arch.Truncation,
+		"truncation strategy for -maxlen (head, tail, or middle)")
 	flag.Parse()
 
 	if samplesPath == "" {
 		essentials.Die("Required flag: -data. See -help.")
 	}
-
-	if err := serializer.LoadAny(modelPath, &trainer.Model); err == nil {
-		log.Println("Loaded model.")
-	} else {
-		log.Println("Creating new model...")
-		trainer.Model = tweeters.NewModel(anyvec32.CurrentCreator(), hidden, dropout)
+	if err := checkObjective(trainer.Objective); err != nil {
+		essentials.Die(err)
 	}
-	trainer.Model.SetDropout(true)
 
 	log.Println("Loading samples...")
 	db, err := tweeters.OpenDB(samplesPath)
@@ -63,29 +114,102 @@ func main() {
 	log.Printf("Samples: %d/%d training/testing users", len(training.UserIndices),
 		len(testing.UserIndices))
 
+	if err := serializer.LoadAny(modelPath, &trainer.Model); err == nil {
+		log.Println("Loaded model.")
+	} else {
+		log.Println("Creating new model...")
+		tok, err := makeTokenizer(tokenizer, vocab, bpeSamples, training)
+		if err != nil {
+			essentials.Die(err)
+		}
+		if archPath != "" {
+			arch, err = tweeters.LoadArchitecture(archPath)
+		} else {
+			arch.Classifier, err = parseSizes(classifier)
+		}
+		if err != nil {
+			essentials.Die(err)
+		}
+		if trainer.LMWeight != 0 {
+			arch.LMHead = true
+		}
+		trainer.Model, err = arch.NewModel(anyvec32.CurrentCreator(), tok)
+		if err != nil {
+			essentials.Die(err)
+		}
+	}
+	if trainer.LMWeight != 0 && trainer.Model.LMHead == nil {
+		essentials.Die("Model has no language model head for -lmweight.")
+	}
+	trainer.Model.SetDropout(true)
+	training.Truncation = trainer.Model.Truncation
+	testing.Truncation = trainer.Model.Truncation
+	if aug != (tweeters.Augmentation{}) {
+		// Validation batches come from testing, which is
+		// never augmented.
+		training.Augmentation = &aug
+	}
+
 	trainer.Samples = training
 
+	var curriculum *Curriculum
+	if curriculumPath != "" {
+		curriculum, err = LoadCurriculum(curriculumPath)
+		if err != nil {
+			essentials.Die(err)
+		}
+		curriculum.Start(&trainer)
+	}
+
 	sgd.Rater = anysgd.ConstRater(stepSize)
 	sgd.Transformer = &anysgd.Adam{}
+	if trainer.FreezeIters > 0 || (encoderStep != 0 && encoderStep != stepSize) {
+		// The encoder gets its own Adam so that its moments
+		// and bias correction start when it is unfrozen.
+		scale := 1.0
+		if encoderStep != 0 {
+			scale = encoderStep / stepSize
+		}
+		sgd.Transformer = &GroupScaler{
+			Transformer:      &anysgd.Adam{},
+			GroupTransformer: &anysgd.Adam{},
+			Vars:             anydiff.NewVarSet(trainer.Model.EncoderParameters()...),
+			Scale:            scale,
+		}
+	}
 	sgd.Fetcher = &trainer
 	sgd.Gradienter = &trainer
 	sgd.Samples = anysgd.LengthSampleList(sgd.BatchSize)
 
 	var iter int
+	lastStatus := time.Now()
 	sgd.StatusFunc = func(b anysgd.Batch) {
+		// Measure training throughput, excluding validation.
+		throughput := float64(len(b.(*Batch).Tweets)) / time.Since(lastStatus).Seconds()
 		if iter%4 == 0 {
 			validator := trainer
 			validator.Samples = testing
+			validator.Buckets = 0
+			trainer.Model.SetDropout(false)
 			batch, err := validator.Fetch(sgd.Samples)
 			if err != nil {
 				essentials.Die(err)
 			}
 			cost := anyvec.Sum(validator.TotalCost(batch.(*Batch)).Output())
-			log.Printf("iter %d: cost=%v validation=%v", iter, trainer.LastCost, cost)
+			log.Printf("iter %d: cost=%v validation=%v tweets/sec=%.1f", iter,
+				trainer.LastCost, cost, throughput)
+			if curriculum != nil && trainer.Objective == BinaryObjective {
+				curriculum.Validated(&trainer, binaryAccuracy(trainer.Model, batch.(*Batch)))
+			}
+			trainer.Model.SetDropout(true)
 		} else {
-			log.Printf("iter %d: cost=%v", iter, trainer.LastCost)
+			log.Printf("iter %d: cost=%v tweets/sec=%.1f", iter, trainer.LastCost, throughput)
+		}
+		if curriculum != nil {
+			curriculum.Step(&trainer)
 		}
 		iter++
+		lastStatus = time.Now()
 	}
 
 	log.Println("Training (ctrl+c to finish)...")
@@ -97,6 +221,35 @@ func main() {
 	}
 }
 
+func parseSizes(list string) ([]int, error) {
+	var res []int
+	for _, field := range strings.Split(list, ",") {
+		if field = strings.TrimSpace(field); field == "" {
+			continue
+		}
+		size, err := strconv.Atoi(field)
+		if err != nil {
+			return nil, errors.New("invalid layer size: " + field)
+		}
+		res = append(res, size)
+	}
+	return res, nil
+}
+
+func makeTokenizer(name string, vocab, bpeSamples int,
+	samples *tweeters.Samples) (tweeters.Tokenizer, error) {
+	var corpus [][]byte
+	if name == "bpe" {
+		log.Println("Training BPE tokenizer...")
+		var err error
+		corpus, err = samples.RandomTweets(bpeSamples)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return tweeters.NewTokenizer(name, vocab, corpus)
+}
+
 // A Trainer fetches batches and computes gradients.
 type Trainer struct {
 	Model   *tweeters.Model
@@ -106,43 +259,201 @@ type Trainer struct {
 	MaxTweets int
 	UserProb  float64
 
+	// Objective is the training objective, such as
+	// BinaryObjective.
+	Objective string
+
+	// Margin is the margin for TripletObjective.
+	Margin float64
+
+	// LMWeight is the weight of the auxiliary language
+	// modeling cost.
+	// If it is 0, the cost is not computed.
+	LMWeight float64
+
+	// Workers, if two or more, is the number of goroutines
+	// to compute gradients with.
+	// See parallelGradient for details.
+	Workers int
+
+	// Negatives, if greater than 1, is the number of
+	// random candidate tweets to consider for each
+	// negative example.
+	// The candidate which the model finds most similar to
+	// the context is used, producing harder negatives.
+	Negatives int
+
+	// Buckets, if greater than 1, is the number of batches
+	// to sample at once and bucket by tweet length.
+	// See tweeters.Samples.BucketedBatches.
+	Buckets int
+
+	// FreezeIters is the number of initial iterations
+	// during which the encoder is not trained.
+	FreezeIters int
+
 	// Set by Gradient().
 	LastCost anyvec.Numeric
+	NumIters int
+
+	// Bucketed batches which have yet to be used.
+	pending []*tweeters.LabeledBatch
 }
 
 // Fetch produces a random batch of samples, using the
 // length of s as the soft-limit on the batch size.
+//
+// For the InfoNCE and triplet objectives, every candidate
+// is a positive example.
 func (t *Trainer) Fetch(s anysgd.SampleList) (anysgd.Batch, error) {
-	tweets, avg, out, err := t.Samples.Batch(t.UserProb, s.Len(), t.MinTweets, t.MaxTweets)
-	if err != nil {
-		return nil, err
+	prob := t.UserProb
+	if t.Objective == InfoNCEObjective || t.Objective == TripletObjective {
+		prob = 1
+	}
+	var batch *tweeters.LabeledBatch
+	if t.Buckets > 1 {
+		if len(t.pending) == 0 {
+			var err error
+			t.pending, err = t.Samples.BucketedBatches(prob, s.Len(), t.MinTweets,
+				t.MaxTweets, t.Buckets)
+			if err != nil {
+				return nil, err
+			}
+		}
+		batch = t.pending[0]
+		t.pending = t.pending[1:]
+	} else {
+		var err error
+		batch, err = t.Samples.LabeledBatch(prob, s.Len(), t.MinTweets, t.MaxTweets)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if t.Negatives > 1 {
+		if err := t.mineNegatives(batch, t.Samples.RandomOtherTweets); err != nil {
+			return nil, err
+		}
 	}
 	cr := t.Model.Parameters()[0].Vector.Creator()
 	return &Batch{
-		Tweets: tweets,
-		Avg:    avg,
-		Out:    anydiff.NewConst(cr.MakeVectorData(cr.MakeNumericList(out))),
+		Tweets: batch.Tweets,
+		Avg:    batch.Avg,
+		Out:    anydiff.NewConst(cr.MakeVectorData(cr.MakeNumericList(batch.Outs))),
 	}, nil
 }
 
+// mineNegatives replaces the candidate of every negative
+// example with the most similar of t.Negatives random
+// tweets, according to the model.
+//
+// The random function produces n random tweets, none of
+// which belong to the given user.
+// Dropout is disabled while scoring the tweets.
+func (t *Trainer) mineNegatives(b *tweeters.LabeledBatch,
+	random func(n, user int) ([][]byte, error)) error {
+	if dropouts := t.Model.Dropouts(); len(dropouts) > 0 && dropouts[0].Enabled {
+		t.Model.SetDropout(false)
+		defer t.Model.SetDropout(true)
+	}
+	tweets := append([][]byte{}, b.Tweets...)
+	var offset int
+	for i, out := range b.Outs {
+		numContext := b.Avg[i*2]
+		if out == 0 {
+			candidates, err := random(t.Negatives, b.Users[i])
+			if err != nil {
+				return err
+			}
+			context := tweeters.NewContext(t.Model, tweets[offset:offset+numContext])
+			scores := context.Score(candidates, len(candidates))
+			best := 0
+			for j, score := range scores {
+				if score > scores[best] {
+					best = j
+				}
+			}
+			tweets[offset+numContext] = candidates[best]
+		}
+		offset += numContext + 1
+	}
+	b.Tweets = tweets
+	return nil
+}
+
 // TotalCost computes the cost for a batch.
 func (t *Trainer) TotalCost(b *Batch) anydiff.Res {
-	latent := t.Model.Averages(b.Tweets, b.Avg)
-	out := t.Model.Classifier.Apply(latent, len(b.Avg)/2)
-	return anynet.SigmoidCE{Average: true}.Cost(b.Out, out, 1)
+	return t.weightedCost(b, 1, 1)
+}
+
+// weightedCost is like TotalCost, but it scales the
+// objective and the language modeling cost separately.
+func (t *Trainer) weightedCost(b *Batch, objWeight, lmWeight float64) anydiff.Res {
+	var cost anydiff.Res
+	switch t.Objective {
+	case InfoNCEObjective:
+		cost = infoNCECost(t.Model, b)
+	case TripletObjective:
+		cost = tripletCost(t.Model, b, t.Margin)
+	default:
+		cost = binaryCost(t.Model, b)
+	}
+	c := cost.Output().Creator()
+	if objWeight != 1 {
+		cost = anydiff.Scale(cost, c.MakeNumeric(objWeight))
+	}
+	if t.LMWeight != 0 {
+		lmCost := t.Model.LanguageModelCost(b.Tweets)
+		cost = anydiff.Add(cost, anydiff.Scale(lmCost, c.MakeNumeric(t.LMWeight*lmWeight)))
+	}
+	return cost
 }
 
 // Gradient computes the gradient for the batch.
+//
+// For the first t.FreezeIters calls, the gradient only
+// includes the classifier parameters.
+// The encoder parameters are added afterwards, so the
+// Transformer should treat them separately (see
+// GroupScaler).
 func (t *Trainer) Gradient(batch anysgd.Batch) anydiff.Grad {
-	grad := anydiff.NewGrad(t.Model.Parameters()...)
+	params := t.Model.TrainableParameters()
+	active := params
+	if t.NumIters < t.FreezeIters {
+		classifier := anydiff.NewVarSet(t.Model.ClassifierParameters()...)
+		active = nil
+		for _, p := range params {
+			if classifier.Has(p) {
+				active = append(active, p)
+			}
+		}
+	} else if t.NumIters == t.FreezeIters && t.FreezeIters > 0 {
+		log.Println("Unfreezing encoder.")
+	}
+	t.NumIters++
 
-	cost := t.TotalCost(batch.(*Batch))
-	t.LastCost = anyvec.Sum(cost.Output())
+	var grad anydiff.Grad
+	if t.Workers > 1 {
+		grad, t.LastCost = t.parallelGradient(batch.(*Batch), active)
+	} else {
+		grad = anydiff.NewGrad(active...)
 
-	c := cost.Output().Creator()
-	one := cost.Output().Creator().MakeVector(1)
-	one.AddScalar(c.MakeNumeric(1))
-	cost.Propagate(one, grad)
+		cost := t.TotalCost(batch.(*Batch))
+		t.LastCost = anyvec.Sum(cost.Output())
+
+		c := cost.Output().Creator()
+		one := cost.Output().Creator().MakeVector(1)
+		one.AddScalar(c.MakeNumeric(1))
+		cost.Propagate(one, grad)
+	}
+
+	// Keep the set of variables constant (until the
+	// encoder is unfrozen) so that stateful transformers
+	// like Adam see every parameter.
+	for _, p := range active {
+		if _, ok := grad[p]; !ok {
+			grad[p] = p.Vector.Creator().MakeVector(p.Vector.Len())
+		}
+	}
 
 	return grad
 }
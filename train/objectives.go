@@ -0,0 +1,198 @@
+package main
+
+import (
+	"errors"
+
+	"github.com/unixpickle/anydiff"
+	"github.com/unixpickle/anynet"
+	"github.com/unixpickle/tweeters"
+)
+
+// Training objectives.
+const (
+	// BinaryObjective classifies each candidate as being
+	// from the same user or not.
+	BinaryObjective = "binary"
+
+	// InfoNCEObjective scores each user's context against
+	// every candidate in the batch and uses a softmax to
+	// identify the user's own candidate.
+	InfoNCEObjective = "infonce"
+
+	// TripletObjective trains the latent space directly
+	// with a margin loss, ignoring the classifier.
+	TripletObjective = "triplet"
+)
+
+func checkObjective(name string) error {
+	switch name {
+	case BinaryObjective, InfoNCEObjective, TripletObjective:
+		return nil
+	default:
+		return errors.New("unknown objective: " + name)
+	}
+}
+
+// binaryCost computes the sigmoid cross-entropy of the
+// classifier's predictions.
+func binaryCost(m *tweeters.Model, b *Batch) anydiff.Res {
+	out := m.Classify(b.Tweets, b.Avg)
+	return anynet.SigmoidCE{Average: true}.Cost(b.Out, out, 1)
+}
+
+// binaryAccuracy computes the fraction of the
+// classifier's predictions which are correct.
+func binaryAccuracy(m *tweeters.Model, b *Batch) float64 {
+	logits := tweeters.VectorFloats(m.Classify(b.Tweets, b.Avg).Output())
+	labels := tweeters.VectorFloats(b.Out.Output())
+	var correct float64
+	for i, logit := range logits {
+		if (logit > 0) == (labels[i] > 0.5) {
+			correct++
+		}
+	}
+	return correct / float64(len(logits))
+}
+
+// infoNCECost computes the contrastive loss for a batch
+// in which every candidate belongs to its context's user.
+//
+// The other candidates in the batch serve as negatives.
+func infoNCECost(m *tweeters.Model, b *Batch) anydiff.Res {
+	n := len(b.Avg) / 2
+	logits := m.PairwiseLogits(b.Tweets, b.Avg)
+	c := logits.Output().Creator()
+	identity := make([]float64, n*n)
+	for i := 0; i < n; i++ {
+		identity[i*n+i] = 1
+	}
+	targets := anydiff.NewConst(c.MakeVectorData(c.MakeNumericList(identity)))
+	logProbs := anydiff.Mul(targets, anydiff.LogSoftmax(logits, n))
+	return anydiff.Scale(anydiff.Sum(logProbs), c.MakeNumeric(-1/float64(n)))
+}
+
+// tripletCost computes a margin loss directly on the
+// averaged latent vectors, using cosine distances.
+//
+// Each context average is an anchor, and its user's
+// candidate is the positive.
+// The negative is chosen from the other candidates in the
+// batch with semi-hard mining (see semiHardNegatives).
+//
+// Batches with fewer than two users have no negatives, so
+// their cost is zero.
+func tripletCost(m *tweeters.Model, b *Batch, margin float64) anydiff.Res {
+	n := len(b.Avg) / 2
+	if n < 2 {
+		c := m.Parameters()[0].Vector.Creator()
+		return anydiff.NewConst(c.MakeVector(1))
+	}
+	return tripletLoss(m.Averages(b.Tweets, b.Avg), n, margin)
+}
+
+// tripletLoss computes the triplet loss for n packed
+// pairs of anchor and positive vectors.
+// There must be at least two pairs.
+func tripletLoss(latent anydiff.Res, n int, margin float64) anydiff.Res {
+	size := latent.Output().Len() / (n * 2)
+	c := latent.Output().Creator()
+	return anydiff.Pool(normalizeRows(latent, n*2), func(vecs anydiff.Res) anydiff.Res {
+		var anchors, positives, negatives []int
+		for i, j := range semiHardNegatives(tweeters.VectorFloats(vecs.Output()), n) {
+			anchors = append(anchors, i*2)
+			positives = append(positives, i*2+1)
+			negatives = append(negatives, j*2+1)
+		}
+		gather := func(rows []int) anydiff.Res {
+			var table []int
+			for _, r := range rows {
+				for k := 0; k < size; k++ {
+					table = append(table, r*size+k)
+				}
+			}
+			return anydiff.Map(c.MakeMapper(vecs.Output().Len(), table), vecs)
+		}
+		return anydiff.Pool(gather(anchors), func(a anydiff.Res) anydiff.Res {
+			rowDist := func(other anydiff.Res) anydiff.Res {
+				return anydiff.SumCols(&anydiff.Matrix{
+					Data: anydiff.Square(anydiff.Sub(a, other)),
+					Rows: n,
+					Cols: size,
+				})
+			}
+			diffs := anydiff.Sub(rowDist(gather(positives)), rowDist(gather(negatives)))
+			losses := anydiff.ClipPos(anydiff.AddScalar(diffs, c.MakeNumeric(margin)))
+			return anydiff.Scale(anydiff.Sum(losses), c.MakeNumeric(1/float64(n)))
+		})
+	})
+}
+
+// semiHardNegatives chooses a negative for each of n
+// packed pairs of anchor and positive vectors.
+// The result maps each anchor to the pair whose positive
+// serves as its negative.
+//
+// Each negative is the closest one which is still farther
+// from the anchor than the positive, meaning that it is
+// within the margin if any negative is.
+// If every negative is closer than the positive, the
+// farthest negative is used.
+func semiHardNegatives(data []float64, n int) []int {
+	size := len(data) / (n * 2)
+	row := func(i int) []float64 {
+		return data[i*size : (i+1)*size]
+	}
+	res := make([]int, n)
+	for i := range res {
+		anchor := row(i * 2)
+		posDist := sqDist(anchor, row(i*2+1))
+		semiHard, farthest := -1, -1
+		dists := make([]float64, n)
+		for j := 0; j < n; j++ {
+			if j == i {
+				continue
+			}
+			dists[j] = sqDist(anchor, row(j*2+1))
+			if dists[j] > posDist && (semiHard == -1 || dists[j] < dists[semiHard]) {
+				semiHard = j
+			}
+			if farthest == -1 || dists[j] > dists[farthest] {
+				farthest = j
+			}
+		}
+		res[i] = semiHard
+		if semiHard == -1 {
+			res[i] = farthest
+		}
+	}
+	return res
+}
+
+// normalizeRows scales each of the n packed vectors to
+// have unit length.
+func normalizeRows(vecs anydiff.Res, n int) anydiff.Res {
+	size := vecs.Output().Len() / n
+	c := vecs.Output().Creator()
+	ones := c.MakeVector(size)
+	ones.AddScalar(c.MakeNumeric(1))
+	return anydiff.Pool(vecs, func(vecs anydiff.Res) anydiff.Res {
+		norms := anydiff.Pow(anydiff.AddScalar(anydiff.SumCols(&anydiff.Matrix{
+			Data: anydiff.Square(vecs),
+			Rows: n,
+			Cols: size,
+		}), c.MakeNumeric(1e-8)), c.MakeNumeric(-0.5))
+		scales := anydiff.MatMul(false, false,
+			&anydiff.Matrix{Data: norms, Rows: n, Cols: 1},
+			&anydiff.Matrix{Data: anydiff.NewConst(ones), Rows: 1, Cols: size},
+		).Data
+		return anydiff.Mul(vecs, scales)
+	})
+}
+
+func sqDist(v1, v2 []float64) float64 {
+	var res float64
+	for i, x := range v1 {
+		res += (x - v2[i]) * (x - v2[i])
+	}
+	return res
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/unixpickle/anydiff"
+	"github.com/unixpickle/anyvec/anyvec64"
+	"github.com/unixpickle/tweeters"
+)
+
+func TestTripletLoss(t *testing.T) {
+	c := anyvec64.CurrentCreator()
+
+	// The first anchor's only negative is closer than its
+	// positive, while the second anchor is already
+	// separated by more than the margin.
+	latent := anydiff.NewVar(c.MakeVectorData([]float64{
+		1, 0, 0, 1,
+		0.8, 0.6, 0.6, 0.8,
+	}))
+	cost := tripletLoss(latent, 2, 0.5)
+	if actual := tweeters.VectorFloats(cost.Output())[0]; math.Abs(actual-0.85) > 1e-5 {
+		t.Errorf("expected cost 0.85 but got %f", actual)
+	}
+
+	grad := anydiff.NewGrad(latent)
+	one := c.MakeVector(1)
+	one.AddScalar(c.MakeNumeric(1))
+	cost.Propagate(one, grad)
+	g := tweeters.VectorFloats(grad[latent])
+
+	// Descent should pull the positive toward the anchor
+	// and push the negative away from it.
+	if dot := -g[2]*(1-0) - g[3]*(0-1); dot <= 0 {
+		t.Errorf("positive gradient has wrong sign: %v", g[2:4])
+	}
+	if dot := -g[6]*(1-0.6) - g[7]*(0-0.8); dot >= 0 {
+		t.Errorf("negative gradient has wrong sign: %v", g[6:8])
+	}
+	if math.Abs(g[4]) > 1e-8 || math.Abs(g[5]) > 1e-8 {
+		t.Errorf("satisfied anchor should have no gradient: %v", g[4:6])
+	}
+}
+
+func TestTripletCostSingleUser(t *testing.T) {
+	model := tweeters.NewModel(anyvec64.CurrentCreator(), nil, 0, 16, 1)
+	batch := &Batch{Tweets: [][]byte{[]byte("hi"), []byte("there")}, Avg: []int{1, 1}}
+	cost := tweeters.VectorFloats(tripletCost(model, batch, 0.5).Output())[0]
+	if cost != 0 {
+		t.Errorf("expected zero cost but got %f", cost)
+	}
+}
+
+func TestSemiHardNegatives(t *testing.T) {
+	// Squared distances from the first anchor are 0.49 for
+	// the positive and 0.09, 0.81, and 4 for the negatives.
+	// Every negative is closer to the second anchor than
+	// its positive.
+	data := []float64{0, 0.7, 10, 0.3, 5, 0.9, -5, 2}
+	negatives := semiHardNegatives(data, 4)
+	if negatives[0] != 2 {
+		t.Errorf("expected semi-hard negative 2 but got %d", negatives[0])
+	}
+	if negatives[1] != 0 {
+		t.Errorf("expected farthest negative 0 but got %d", negatives[1])
+	}
+	for i, j := range negatives {
+		if i == j {
+			t.Errorf("anchor %d is its own negative", i)
+		}
+	}
+}
+
+func TestMineNegatives(t *testing.T) {
+	model := tweeters.NewModel(anyvec64.CurrentCreator(), nil, 0, 16, 0.5)
+	model.SetDropout(true)
+	tweets := [][]byte{
+		[]byte("hello"), []byte("world"), []byte("positive"),
+		[]byte("foo"), []byte("negative"),
+	}
+	batch := &tweeters.LabeledBatch{
+		Tweets: append([][]byte{}, tweets...),
+		Avg:    []int{2, 1, 1, 1},
+		Outs:   []float64{1, 0},
+		Users:  []int{7, 3},
+	}
+	candidates := [][]byte{[]byte("abc"), []byte("hello world"), []byte("x"),
+		[]byte("foo bar")}
+	var calls int
+	random := func(n, user int) ([][]byte, error) {
+		calls++
+		if n != len(candidates) {
+			t.Fatalf("expected %d candidates but got %d", len(candidates), n)
+		}
+		if user != 3 {
+			t.Errorf("expected to exclude user 3 but got %d", user)
+		}
+		if model.Dropouts()[0].Enabled {
+			t.Error("dropout should be disabled while mining")
+		}
+		return candidates, nil
+	}
+
+	original := batch.Tweets
+	trainer := &Trainer{Model: model, Negatives: len(candidates)}
+	if err := trainer.mineNegatives(batch, random); err != nil {
+		t.Fatal(err)
+	}
+	if calls != 1 {
+		t.Errorf("expected 1 call but got %d", calls)
+	}
+	if !model.Dropouts()[0].Enabled {
+		t.Error("dropout was not re-enabled")
+	}
+
+	model.SetDropout(false)
+	scores := tweeters.NewContext(model, tweets[3:4]).Score(candidates, len(candidates))
+	best := 0
+	for i, score := range scores {
+		if score > scores[best] {
+			best = i
+		}
+	}
+	expected := append(append([][]byte{}, tweets[:4]...), candidates[best])
+	if !reflect.DeepEqual(batch.Tweets, expected) {
+		t.Errorf("expected %q but got %q", expected, batch.Tweets)
+	}
+	if string(original[4]) != "negative" {
+		t.Error("original tweet slice was modified")
+	}
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"sync"
+
+	"github.com/unixpickle/anydiff"
+	"github.com/unixpickle/anyvec"
+	"github.com/unixpickle/essentials"
+	"github.com/unixpickle/tweeters"
+)
+
+// parallelGradient computes the gradient of the total
+// cost for a batch by splitting the batch's users into
+// one shard per worker and processing the shards in
+// concurrent goroutines.
+//
+// Every goroutine shares t.Model, which is safe since the
+// model is not modified while computing gradients.
+// Thus, this only provides parallelism across CPU
+// goroutines, not across devices.
+//
+// Each shard's objective is weighted by its fraction of
+// the batch's users, and its language modeling cost by its
+// fraction of the batch's next-token predictions, so that
+// the summed gradient matches the gradient of the whole
+// batch.
+// For the InfoNCE and triplet objectives, negatives are
+// only drawn from within a shard, so every shard gets at
+// least two users, even if some workers go unused.
+func (t *Trainer) parallelGradient(b *Batch, params []*anydiff.Var) (anydiff.Grad,
+	anyvec.Numeric) {
+	minUsers := 1
+	if t.Objective == InfoNCEObjective || t.Objective == TripletObjective {
+		minUsers = 2
+	}
+	shards, weights := splitBatch(b, t.Workers, minUsers)
+	lmWeights := weights
+	if t.LMWeight != 0 {
+		lmWeights = predictionFractions(t.Model, shards)
+	}
+	grads := make([]anydiff.Grad, len(shards))
+	costs := make([]float64, len(shards))
+
+	c := params[0].Vector.Creator()
+	var wg sync.WaitGroup
+	for i, shard := range shards {
+		wg.Add(1)
+		go func(i int, shard *Batch) {
+			defer wg.Done()
+			cost := t.weightedCost(shard, weights[i], lmWeights[i])
+			grads[i] = anydiff.NewGrad(params...)
+			one := c.MakeVector(1)
+			one.AddScalar(c.MakeNumeric(1))
+			cost.Propagate(one, grads[i])
+			costs[i] = tweeters.VectorFloats(cost.Output())[0]
+		}(i, shard)
+	}
+	wg.Wait()
+
+	grad := grads[0]
+	totalCost := costs[0]
+	for i, shardGrad := range grads[1:] {
+		for v, vec := range shardGrad {
+			grad[v].Add(vec)
+		}
+		totalCost += costs[i+1]
+	}
+	return grad, c.MakeNumeric(totalCost)
+}
+
+// splitBatch splits a batch into at most n shards with
+// roughly equal numbers of users, and at least minUsers
+// users per shard (unless the batch itself is smaller).
+//
+// It also returns each shard's fraction of the users.
+func splitBatch(b *Batch, n, minUsers int) ([]*Batch, []float64) {
+	numUsers := len(b.Avg) / 2
+	if n > numUsers/minUsers {
+		n = essentials.MaxInt(1, numUsers/minUsers)
+	}
+	labels := b.Out.Output()
+	var shards []*Batch
+	var weights []float64
+	var tweetIdx int
+	for i := 0; i < n; i++ {
+		start := i * numUsers / n
+		end := (i + 1) * numUsers / n
+		avg := b.Avg[start*2 : end*2]
+		numTweets := 0
+		for _, size := range avg {
+			numTweets += size
+		}
+		shards = append(shards, &Batch{
+			Tweets: b.Tweets[tweetIdx : tweetIdx+numTweets],
+			Avg:    avg,
+			Out:    anydiff.NewConst(labels.Slice(start, end)),
+		})
+		weights = append(weights, float64(end-start)/float64(numUsers))
+		tweetIdx += numTweets
+	}
+	return shards, weights
+}
+
+// predictionFractions computes each shard's fraction of
+// the next-token predictions made by the language model.
+func predictionFractions(m *tweeters.Model, shards []*Batch) []float64 {
+	res := make([]float64, len(shards))
+	var total float64
+	for i, shard := range shards {
+		res[i] = float64(m.NumPredictions(shard.Tweets))
+		total += res[i]
+	}
+	if total > 0 {
+		for i := range res {
+			res[i] /= total
+		}
+	}
+	return res
+}
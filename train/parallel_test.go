@@ -0,0 +1,100 @@
+package main
+
+import (
+	"math"
+	"testing"
+
+	"github.com/unixpickle/anydiff"
+	"github.com/unixpickle/anyvec/anyvec64"
+	"github.com/unixpickle/tweeters"
+)
+
+func TestParallelGradient(t *testing.T) {
+	c := anyvec64.CurrentCreator()
+	model := tweeters.NewModel(c, nil, 0, 16, 1)
+	batch := testParallelBatch()
+	single := &Trainer{Model: model, Objective: BinaryObjective}
+	for _, workers := range []int{2, 3, 8} {
+		checkParallelGradient(t, single, workers, batch)
+	}
+}
+
+func TestParallelGradientLM(t *testing.T) {
+	arch := &tweeters.Architecture{Cell: "lstm", Layers: 1, Hidden: 8, Pooling: "tail",
+		Aggregator: "mean", LMHead: true, Dropout: 1}
+	model, err := arch.NewModel(anyvec64.CurrentCreator(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	single := &Trainer{Model: model, Objective: BinaryObjective, LMWeight: 0.5}
+	checkParallelGradient(t, single, 3, testParallelBatch())
+}
+
+func TestParallelGradientTriplet(t *testing.T) {
+	c := anyvec64.CurrentCreator()
+	model := tweeters.NewModel(c, nil, 0, 16, 1)
+	batch := testParallelBatch()
+
+	// With 4 users, at most 2 shards can have 2 users.
+	shards, weights := splitBatch(batch, 3, 2)
+	if len(shards) != 2 {
+		t.Fatalf("expected 2 shards but got %d", len(shards))
+	}
+	var expected float64
+	for i, shard := range shards {
+		if len(shard.Avg) != 4 {
+			t.Errorf("shard %d: expected 2 users but got %d", i, len(shard.Avg)/2)
+		}
+		cost := tripletCost(model, shard, 0.2).Output()
+		expected += weights[i] * tweeters.VectorFloats(cost)[0]
+	}
+
+	trainer := &Trainer{Model: model, Objective: TripletObjective, Margin: 0.2,
+		Workers: 3}
+	trainer.Gradient(batch)
+	if actual := trainer.LastCost.(float64); math.Abs(actual-expected) > 1e-8 {
+		t.Errorf("expected cost %f but got %f", expected, actual)
+	}
+}
+
+func testParallelBatch() *Batch {
+	c := anyvec64.CurrentCreator()
+	return &Batch{
+		Tweets: [][]byte{
+			[]byte("hello"), []byte("world"), []byte("hi"),
+			[]byte("foo"), []byte("bar"),
+			[]byte("a"), []byte("bc"), []byte("def"), []byte("ghij"),
+			[]byte("xyz"), []byte("test"),
+		},
+		Avg: []int{2, 1, 1, 1, 3, 1, 1, 1},
+		Out: anydiff.NewConst(c.MakeVectorData([]float64{1, 0, 1, 0})),
+	}
+}
+
+// checkParallelGradient checks that a trainer gives the
+// same gradient and cost with and without workers.
+func checkParallelGradient(t *testing.T, single *Trainer, workers int, batch *Batch) {
+	expected := single.Gradient(batch)
+	expectedCost := single.LastCost.(float64)
+
+	parallel := *single
+	parallel.Workers = workers
+	actual := parallel.Gradient(batch)
+	if cost := parallel.LastCost.(float64); math.Abs(cost-expectedCost) > 1e-8 {
+		t.Errorf("%d workers: expected cost %f but got %f", workers,
+			expectedCost, cost)
+	}
+	if len(actual) != len(expected) {
+		t.Fatalf("%d workers: expected %d gradients but got %d", workers,
+			len(expected), len(actual))
+	}
+	for v, expectedVec := range expected {
+		diff := actual[v].Copy()
+		diff.Sub(expectedVec)
+		for _, x := range diff.Data().([]float64) {
+			if math.Abs(x) > 1e-8 {
+				t.Fatalf("%d workers: gradient mismatch of %e", workers, x)
+			}
+		}
+	}
+}
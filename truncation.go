@@ -0,0 +1,156 @@
+package tweeters
+
+import (
+	"errors"
+	"fmt"
+	"unicode/utf8"
+
+	"github.com/unixpickle/essentials"
+	"github.com/unixpickle/serializer"
+)
+
+func init() {
+	serializer.RegisterTypedDeserializer((&Truncation{}).SerializerType(),
+		DeserializeTruncation)
+}
+
+// Truncation strategies determine which part of a long
+// tweet is kept.
+const (
+	// HeadTruncation keeps the beginning of a tweet.
+	HeadTruncation = "head"
+
+	// TailTruncation keeps the end of a tweet.
+	TailTruncation = "tail"
+
+	// MiddleTruncation keeps the beginning and the end of
+	// a tweet, dropping the middle.
+	MiddleTruncation = "middle"
+)
+
+// Truncation limits the number of bytes in a tweet.
+//
+// Cuts are moved to UTF-8 code point boundaries, so a
+// truncated tweet may be slightly shorter than MaxLen.
+type Truncation struct {
+	MaxLen   int
+	Strategy string
+}
+
+// NewTruncation creates a Truncation with the given
+// maximum length and strategy.
+//
+// If maxLen is 0, nil is returned, indicating that tweets
+// should not be truncated.
+func NewTruncation(maxLen int, strategy string) (*Truncation, error) {
+	if maxLen == 0 {
+		return nil, nil
+	}
+	res := &Truncation{MaxLen: maxLen, Strategy: strategy}
+	if err := res.Validate(); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// DeserializeTruncation deserializes a Truncation.
+func DeserializeTruncation(d []byte) (*Truncation, error) {
+	var maxLen serializer.Int
+	var strategy serializer.String
+	if err := serializer.DeserializeAny(d, &maxLen, &strategy); err != nil {
+		return nil, essentials.AddCtx("deserialize Truncation", err)
+	}
+	res := &Truncation{MaxLen: int(maxLen), Strategy: string(strategy)}
+	if err := res.Validate(); err != nil {
+		return nil, essentials.AddCtx("deserialize Truncation", err)
+	}
+	return res, nil
+}
+
+// Validate checks that the maximum length and strategy
+// are valid.
+func (t *Truncation) Validate() error {
+	switch t.Strategy {
+	case HeadTruncation, TailTruncation, MiddleTruncation:
+	default:
+		return errors.New("unknown truncation strategy: " + t.Strategy)
+	}
+	if t.MaxLen < 1 {
+		return fmt.Errorf("invalid maximum length: %d", t.MaxLen)
+	}
+	return nil
+}
+
+// Truncate truncates a tweet.
+//
+// If the tweet is short enough, it is returned as-is.
+func (t *Truncation) Truncate(tweet []byte) []byte {
+	headEnd, tailStart := t.split(tweet)
+	if headEnd == tailStart {
+		return tweet
+	}
+	return append(append([]byte{}, tweet[:headEnd]...), tweet[tailStart:]...)
+}
+
+// SerializerType returns the unique ID used to serialize
+// a Truncation with the serializer package.
+func (t *Truncation) SerializerType() string {
+	return "github.com/unixpickle/tweeters.Truncation"
+}
+
+// Serialize serializes the Truncation.
+func (t *Truncation) Serialize() ([]byte, error) {
+	return serializer.SerializeAny(serializer.Int(t.MaxLen), serializer.String(t.Strategy))
+}
+
+// split finds the bytes to keep from a tweet.
+//
+// The kept bytes are tweet[:headEnd] and
+// tweet[tailStart:].
+// If nothing is removed, headEnd == tailStart.
+func (t *Truncation) split(tweet []byte) (headEnd, tailStart int) {
+	if len(tweet) <= t.MaxLen {
+		return len(tweet), len(tweet)
+	}
+	switch t.Strategy {
+	case HeadTruncation:
+		headEnd, tailStart = t.MaxLen, len(tweet)
+	case TailTruncation:
+		headEnd, tailStart = 0, len(tweet)-t.MaxLen
+	case MiddleTruncation:
+		headEnd = (t.MaxLen + 1) / 2
+		tailStart = len(tweet) - t.MaxLen/2
+	default:
+		panic("unknown truncation strategy: " + t.Strategy)
+	}
+	for headEnd > 0 && !utf8.RuneStart(tweet[headEnd]) {
+		headEnd--
+	}
+	for tailStart < len(tweet) && !utf8.RuneStart(tweet[tailStart]) {
+		tailStart++
+	}
+	return
+}
+
+// truncateTokens tokenizes a tweet after truncating it.
+//
+// The token offsets refer to bytes in the original,
+// untruncated tweet.
+func (t *Truncation) truncateTokens(tok Tokenizer, tweet []byte) []Token {
+	headEnd, tailStart := t.split(tweet)
+	if headEnd == tailStart {
+		return tok.Tokenize(tweet)
+	}
+	truncated := append(append([]byte{}, tweet[:headEnd]...), tweet[tailStart:]...)
+	tokens := tok.Tokenize(truncated)
+	removed := tailStart - headEnd
+	for i := range tokens {
+		if tokens[i].Start >= headEnd {
+			tokens[i].Start += removed
+		}
+		if tokens[i].End > headEnd {
+			tokens[i].End += removed
+		}
+	}
+	return tokens
+}
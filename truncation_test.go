@@ -0,0 +1,65 @@
+package tweeters
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/unixpickle/serializer"
+)
+
+func TestTruncation(t *testing.T) {
+	tweet := []byte("abcdefghij")
+	for strategy, expected := range map[string]string{
+		HeadTruncation:   "abcdef",
+		TailTruncation:   "efghij",
+		MiddleTruncation: "abchij",
+	} {
+		trunc := &Truncation{MaxLen: 6, Strategy: strategy}
+		if actual := string(trunc.Truncate(tweet)); actual != expected {
+			t.Errorf("%s: expected %q but got %q", strategy, expected, actual)
+		}
+		if actual := string(trunc.Truncate([]byte("abc"))); actual != "abc" {
+			t.Errorf("%s: short tweet changed to %q", strategy, actual)
+		}
+	}
+
+	// Cuts should not split code points.
+	trunc := &Truncation{MaxLen: 4, Strategy: HeadTruncation}
+	if actual := string(trunc.Truncate([]byte("abcéf"))); actual != "abc" {
+		t.Errorf("expected %q but got %q", "abc", actual)
+	}
+	trunc.Strategy = TailTruncation
+	if actual := string(trunc.Truncate([]byte("aébcd"))); actual != "bcd" {
+		t.Errorf("expected %q but got %q", "bcd", actual)
+	}
+}
+
+func TestTruncationTokens(t *testing.T) {
+	trunc := &Truncation{MaxLen: 4, Strategy: MiddleTruncation}
+	tokens := trunc.truncateTokens(&ByteTokenizer{}, []byte("abcdefgh"))
+	var starts []int
+	for _, tok := range tokens {
+		starts = append(starts, tok.Start)
+	}
+	if expected := []int{0, 1, 6, 7}; !reflect.DeepEqual(starts, expected) {
+		t.Errorf("expected starts %v but got %v", expected, starts)
+	}
+}
+
+func TestTruncationSerialize(t *testing.T) {
+	trunc := &Truncation{MaxLen: 140, Strategy: TailTruncation}
+	data, err := serializer.SerializeAny(trunc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded *Truncation
+	if err := serializer.DeserializeAny(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(trunc, decoded) {
+		t.Errorf("expected %v but got %v", trunc, decoded)
+	}
+	if _, err := NewTruncation(10, "bogus"); err == nil {
+		t.Error("expected error for unknown strategy")
+	}
+}